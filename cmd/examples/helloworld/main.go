@@ -36,6 +36,7 @@ func main() {
 		}
 
 		sse := datastar.NewSSE(w, r)
+		defer sse.Close()
 
 		for i := 0; i < len(message); i++ {
 			if err := sse.PatchElements(`<div id="message">` + message[:i+1] + `</div>`); err != nil {
@@ -22,6 +22,7 @@ var hotReloadOnlyOnce sync.Once
 
 func HotReloadHandler(w http.ResponseWriter, r *http.Request) {
 	sse := datastar.NewSSE(w, r)
+	defer sse.Close()
 	hotReloadOnlyOnce.Do(func() {
 		// Refresh the client page as soon as connection
 		// is established. This will occur only once
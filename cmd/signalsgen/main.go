@@ -0,0 +1,143 @@
+// Command signalsgen generates TypeScript declarations and a JSON Schema for
+// the signal types that Go packages register with datastar.RegisterSignals.
+//
+// Like mockgen's reflect mode, it writes a small program that imports the
+// packages, so their registrations run, and prints the declarations using
+// reflection. The program is built inside the current module, so run
+// signalsgen from the module that contains the packages:
+//
+//	signalsgen -out web/signals ./internal/views
+//
+// This writes web/signals.d.ts and web/signals.schema.json. It fits a
+// go:generate directive:
+//
+//	//go:generate go run github.com/starfederation/datastar-go/cmd/signalsgen -out ../web/signals .
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+func main() {
+	out := flag.String("out", "signals", "output path without extension; writes <out>.d.ts and <out>.schema.json")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: signalsgen [-out path] package...\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	pkgs, err := resolvePackages(flag.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error resolving packages: %v\n", err)
+		os.Exit(1)
+	}
+
+	outPath, err := filepath.Abs(*out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error resolving output path: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating output directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := runReflectProgram(pkgs, outPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating signals: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Generated %s.d.ts and %s.schema.json\n", *out, *out)
+}
+
+// resolvePackages turns package patterns such as ./views into import paths.
+func resolvePackages(patterns []string) ([]string, error) {
+	args := append([]string{"list", "-f", "{{.ImportPath}}"}, patterns...)
+	cmd := exec.Command("go", args...)
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list failed: %w", err)
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// runReflectProgram writes the reflect program into a temporary directory of
+// the current module and runs it.
+func runReflectProgram(pkgs []string, outPath string) error {
+	// the program must live inside the module to import its packages
+	tmpDir, err := os.MkdirTemp(".", "signalsgen_reflect_")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var program bytes.Buffer
+	if err := reflectProgram.Execute(&program, map[string]any{
+		"Packages": pkgs,
+		"Out":      outPath,
+	}); err != nil {
+		return fmt.Errorf("failed to render reflect program: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), program.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write reflect program: %w", err)
+	}
+
+	cmd := exec.Command("go", "run", "./"+filepath.Base(tmpDir))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("reflect program failed: %w", err)
+	}
+	return nil
+}
+
+var reflectProgram = template.Must(template.New("reflect").Parse(`// Code generated by signalsgen. DO NOT EDIT.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/starfederation/datastar-go/datastar"
+{{- range .Packages}}
+	_ {{printf "%q" .}}
+{{- end}}
+)
+
+func main() {
+	if err := write({{printf "%q" .Out}}+".d.ts", datastar.WriteSignalsTypeScript); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := write({{printf "%q" .Out}}+".schema.json", datastar.WriteSignalsSchema); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func write(path string, gen func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := gen(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+`))
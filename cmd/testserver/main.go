@@ -53,6 +53,7 @@ func testHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Create SSE handler
 	sse := datastar.NewSSE(w, r)
+	defer sse.Close()
 
 	// Process each event
 	for _, event := range req.Events {
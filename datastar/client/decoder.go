@@ -0,0 +1,271 @@
+// Package client decodes Datastar server-sent event streams.
+//
+// It is the reading counterpart of the datastar package and is useful for
+// integration tests, command line tools and Go programs that drive
+// Datastar endpoints.
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+// maxLineSize bounds a single line of the stream. Elements are sent one
+// line per data field, so this only needs to fit the longest HTML line.
+const maxLineSize = 16 << 20
+
+// Event is a decoded server-sent event.
+// It is one of [PatchElementsEvent], [PatchSignalsEvent] or [RawEvent].
+type Event interface {
+	// EventType returns the value of the event field.
+	EventType() datastar.EventType
+	// Meta returns the fields shared by all server-sent events.
+	Meta() EventMeta
+}
+
+// EventMeta holds the fields shared by all server-sent events.
+type EventMeta struct {
+	// ID is the last event ID seen on the stream when this event was dispatched.
+	ID string
+	// Retry is the reconnection time sent with this event, or 0 when absent.
+	Retry time.Duration
+}
+
+// RawEvent is an event whose type is not a known Datastar event type.
+type RawEvent struct {
+	EventMeta
+	Type datastar.EventType
+	Data []string
+}
+
+// EventType returns the value of the event field.
+func (e RawEvent) EventType() datastar.EventType { return e.Type }
+
+// Meta returns the fields shared by all server-sent events.
+func (e RawEvent) Meta() EventMeta { return e.EventMeta }
+
+// PatchElementsEvent is a decoded [datastar.EventTypePatchElements] event.
+type PatchElementsEvent struct {
+	EventMeta
+	Selector          string
+	Mode              datastar.ElementPatchMode
+	Namespace         datastar.Namespace
+	UseViewTransition bool
+	Elements          string
+	// Extra holds data lines with an unknown key, verbatim and in order,
+	// so streams from newer servers still decode.
+	Extra []string
+}
+
+// EventType returns [datastar.EventTypePatchElements].
+func (e PatchElementsEvent) EventType() datastar.EventType { return datastar.EventTypePatchElements }
+
+// Meta returns the fields shared by all server-sent events.
+func (e PatchElementsEvent) Meta() EventMeta { return e.EventMeta }
+
+// PatchSignalsEvent is a decoded [datastar.EventTypePatchSignals] event.
+type PatchSignalsEvent struct {
+	EventMeta
+	// Signals is the JSON-encoded signals payload.
+	Signals       []byte
+	OnlyIfMissing bool
+	// Extra holds data lines with an unknown key, verbatim and in order,
+	// so streams from newer servers still decode.
+	Extra []string
+}
+
+// EventType returns [datastar.EventTypePatchSignals].
+func (e PatchSignalsEvent) EventType() datastar.EventType { return datastar.EventTypePatchSignals }
+
+// Meta returns the fields shared by all server-sent events.
+func (e PatchSignalsEvent) Meta() EventMeta { return e.EventMeta }
+
+// Decoder reads and decodes server-sent events from an input stream.
+type Decoder struct {
+	scanner     *bufio.Scanner
+	lastEventID string
+	retry       time.Duration
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+	scanner.Split(scanLines)
+	return &Decoder{scanner: scanner}
+}
+
+// LastEventID returns the last event ID received on the stream.
+// It is the value a browser would send in the Last-Event-ID header on reconnect.
+func (d *Decoder) LastEventID() string {
+	return d.lastEventID
+}
+
+// Retry returns the last reconnection time received on the stream.
+func (d *Decoder) Retry() time.Duration {
+	return d.retry
+}
+
+// Next reads the next event from the stream. Comment lines are skipped.
+// It returns [io.EOF] once the stream ends.
+func (d *Decoder) Next() (Event, error) {
+	var (
+		eventType string
+		data      []string
+		hasData   bool
+		retry     time.Duration
+	)
+
+	for d.scanner.Scan() {
+		line := d.scanner.Text()
+
+		if line == "" {
+			if !hasData {
+				// nothing to dispatch, reset the buffers as the spec requires
+				eventType, retry = "", 0
+				continue
+			}
+			meta := EventMeta{ID: d.lastEventID, Retry: retry}
+			return decodeEvent(datastar.EventType(eventType), meta, data)
+		}
+
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data = append(data, value)
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				d.lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				retry = time.Duration(ms) * time.Millisecond
+				d.retry = retry
+			}
+		}
+	}
+
+	if err := d.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+	return nil, io.EOF
+}
+
+func decodeEvent(eventType datastar.EventType, meta EventMeta, data []string) (Event, error) {
+	switch eventType {
+	case datastar.EventTypePatchElements:
+		return decodePatchElements(meta, data)
+	case datastar.EventTypePatchSignals:
+		return decodePatchSignals(meta, data)
+	default:
+		if eventType == "" {
+			eventType = "message"
+		}
+		return RawEvent{EventMeta: meta, Type: eventType, Data: data}, nil
+	}
+}
+
+func decodePatchElements(meta EventMeta, data []string) (Event, error) {
+	evt := PatchElementsEvent{
+		EventMeta: meta,
+		Mode:      datastar.DefaultElementPatchMode,
+		Namespace: datastar.NamespaceHTML,
+	}
+
+	elements := make([]string, 0, len(data))
+	for _, line := range data {
+		switch {
+		case strings.HasPrefix(line, datastar.SelectorDatalineLiteral):
+			evt.Selector = strings.TrimPrefix(line, datastar.SelectorDatalineLiteral)
+		case strings.HasPrefix(line, datastar.ModeDatalineLiteral):
+			mode, err := datastar.ElementPatchModeFromString(strings.TrimPrefix(line, datastar.ModeDatalineLiteral))
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode patch elements: %w", err)
+			}
+			evt.Mode = mode
+		case strings.HasPrefix(line, datastar.NamespaceDatalineLiteral):
+			namespace, err := datastar.NamespaceFromString(strings.TrimPrefix(line, datastar.NamespaceDatalineLiteral))
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode patch elements: %w", err)
+			}
+			evt.Namespace = namespace
+		case strings.HasPrefix(line, datastar.UseViewTransitionDatalineLiteral):
+			b, err := strconv.ParseBool(strings.TrimPrefix(line, datastar.UseViewTransitionDatalineLiteral))
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode patch elements: invalid useViewTransition: %w", err)
+			}
+			evt.UseViewTransition = b
+		case strings.HasPrefix(line, datastar.ElementsDatalineLiteral):
+			elements = append(elements, strings.TrimPrefix(line, datastar.ElementsDatalineLiteral))
+		default:
+			evt.Extra = append(evt.Extra, line)
+		}
+	}
+	evt.Elements = strings.Join(elements, datastar.NewLine)
+
+	return evt, nil
+}
+
+func decodePatchSignals(meta EventMeta, data []string) (Event, error) {
+	evt := PatchSignalsEvent{EventMeta: meta}
+
+	signals := make([]string, 0, len(data))
+	for _, line := range data {
+		switch {
+		case strings.HasPrefix(line, datastar.OnlyIfMissingDatalineLiteral):
+			b, err := strconv.ParseBool(strings.TrimPrefix(line, datastar.OnlyIfMissingDatalineLiteral))
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode patch signals: invalid onlyIfMissing: %w", err)
+			}
+			evt.OnlyIfMissing = b
+		case strings.HasPrefix(line, datastar.SignalsDatalineLiteral):
+			signals = append(signals, strings.TrimPrefix(line, datastar.SignalsDatalineLiteral))
+		default:
+			evt.Extra = append(evt.Extra, line)
+		}
+	}
+	evt.Signals = []byte(strings.Join(signals, datastar.NewLine))
+
+	return evt, nil
+}
+
+// scanLines is a [bufio.SplitFunc] that accepts the three line endings
+// allowed by the server-sent events specification: CRLF, LF and CR.
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		// a CR at the end of the buffer may be followed by a LF
+		if i+1 == len(data) && !atEOF {
+			return 0, nil, nil
+		}
+		if i+1 < len(data) && data[i+1] == '\n' {
+			return i + 2, data[:i], nil
+		}
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
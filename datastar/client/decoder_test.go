@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+func TestDecodeRoundTrip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sse := datastar.NewSSE(w, r)
+		sse.PatchElements("<div id=\"a\">\n  hi\n</div>",
+			datastar.WithSelectorID("a"),
+			datastar.WithModeInner(),
+			datastar.WithNamespaceSVG(),
+			datastar.WithViewTransitions(),
+			datastar.WithPatchElementsEventID("1"),
+			datastar.WithRetryDuration(5*time.Second),
+		)
+		sse.PatchSignals([]byte("{\n\"a\":1\n}"), datastar.WithOnlyIfMissing(true))
+	}))
+	defer srv.Close()
+
+	stream, err := Get(context.Background(), srv.URL, map[string]any{"x": 1})
+	if err != nil {
+		t.Fatalf("Expected no error opening stream, got: %v", err)
+	}
+	defer stream.Close()
+
+	evt, err := stream.Next()
+	if err != nil {
+		t.Fatalf("Expected no error decoding, got: %v", err)
+	}
+	pe, ok := evt.(PatchElementsEvent)
+	if !ok {
+		t.Fatalf("Expected PatchElementsEvent, got: %T", evt)
+	}
+	if pe.Selector != "#a" || pe.Mode != datastar.ElementPatchModeInner || pe.Namespace != datastar.NamespaceSVG || !pe.UseViewTransition {
+		t.Errorf("Unexpected patch elements options: %+v", pe)
+	}
+	if pe.Elements != "<div id=\"a\">\n  hi\n</div>" {
+		t.Errorf("Unexpected elements: %q", pe.Elements)
+	}
+	if pe.ID != "1" || pe.Retry != 5*time.Second {
+		t.Errorf("Unexpected meta: %+v", pe.EventMeta)
+	}
+
+	evt, err = stream.Next()
+	if err != nil {
+		t.Fatalf("Expected no error decoding, got: %v", err)
+	}
+	ps, ok := evt.(PatchSignalsEvent)
+	if !ok {
+		t.Fatalf("Expected PatchSignalsEvent, got: %T", evt)
+	}
+	if string(ps.Signals) != "{\n\"a\":1\n}" || !ps.OnlyIfMissing {
+		t.Errorf("Unexpected patch signals: %+v", ps)
+	}
+	if ps.ID != "1" {
+		t.Errorf("Expected last event ID to carry over, got: %q", ps.ID)
+	}
+
+	if _, err := stream.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected io.EOF at end of stream, got: %v", err)
+	}
+}
+
+func TestDecodeWireFormat(t *testing.T) {
+	input := ": comment\r\n" +
+		"retry: 300\r" +
+		"event: custom\r\n" +
+		"data: one\n" +
+		"data:two\n" +
+		"\n" +
+		"\n" +
+		"id: 7\n" +
+		"\n" +
+		"data: three"
+
+	d := NewDecoder(strings.NewReader(input))
+
+	evt, err := d.Next()
+	if err != nil {
+		t.Fatalf("Expected no error decoding, got: %v", err)
+	}
+	raw, ok := evt.(RawEvent)
+	if !ok {
+		t.Fatalf("Expected RawEvent, got: %T", evt)
+	}
+	if raw.Type != "custom" || raw.Retry != 300*time.Millisecond || len(raw.Data) != 2 || raw.Data[0] != "one" || raw.Data[1] != "two" {
+		t.Errorf("Unexpected raw event: %+v", raw)
+	}
+
+	// an event without a trailing blank line is never dispatched
+	if _, err := d.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected io.EOF, got: %v", err)
+	}
+	if d.LastEventID() != "7" {
+		t.Errorf("Expected last event ID 7, got: %q", d.LastEventID())
+	}
+}
+
+func TestDecodeUnknownDataLines(t *testing.T) {
+	input := "event: datastar-patch-elements\n" +
+		"data: selector #a\n" +
+		"data: priority high\n" +
+		"data: elements <div id=\"a\"></div>\n" +
+		"\n" +
+		"event: datastar-patch-signals\n" +
+		"data: signals {\"a\":1}\n" +
+		"data: ttl 5\n" +
+		"\n"
+
+	d := NewDecoder(strings.NewReader(input))
+
+	evt, err := d.Next()
+	if err != nil {
+		t.Fatalf("Expected no error decoding elements, got: %v", err)
+	}
+	elements := evt.(PatchElementsEvent)
+	if elements.Selector != "#a" || elements.Elements != `<div id="a"></div>` || len(elements.Extra) != 1 || elements.Extra[0] != "priority high" {
+		t.Errorf("Unexpected patch elements event: %+v", elements)
+	}
+
+	evt, err = d.Next()
+	if err != nil {
+		t.Fatalf("Expected no error decoding signals, got: %v", err)
+	}
+	signals := evt.(PatchSignalsEvent)
+	if string(signals.Signals) != `{"a":1}` || len(signals.Extra) != 1 || signals.Extra[0] != "ttl 5" {
+		t.Errorf("Unexpected patch signals event: %+v", signals)
+	}
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+// Stream is a [Decoder] reading from an HTTP response body.
+// It must be closed when no longer needed.
+type Stream struct {
+	*Decoder
+	Response *http.Response
+}
+
+// Close closes the response body.
+func (s *Stream) Close() error {
+	return s.Response.Body.Close()
+}
+
+// Get opens a Datastar stream with a GET request to rawURL using [http.DefaultClient].
+// The signals are JSON-encoded into the `datastar` query parameter the same way
+// the browser does. Pass nil signals to send none.
+func Get(ctx context.Context, rawURL string, signals any) (*Stream, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse url: %w", err)
+	}
+
+	if signals != nil {
+		b, err := json.Marshal(signals)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal signals: %w", err)
+		}
+		q := u.Query()
+		q.Set(datastar.DatastarKey, string(b))
+		u.RawQuery = q.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	return Do(http.DefaultClient, req)
+}
+
+// Do sends an HTTP request with the headers of a Datastar backend action and
+// returns the decoded event stream. A nil client uses [http.DefaultClient].
+// It returns an error for non-2xx responses.
+func Do(client *http.Client, req *http.Request) (*Stream, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Datastar-Request", "true")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, body)
+	}
+
+	return &Stream{
+		Decoder:  NewDecoder(resp.Body),
+		Response: resp,
+	}, nil
+}
@@ -0,0 +1,266 @@
+// Package datastartest provides utilities for testing Datastar handlers.
+//
+// A [Recorder] runs an [http.Handler], decodes the events it emitted and
+// exposes them as typed values together with assertion helpers.
+package datastartest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/starfederation/datastar-go/datastar"
+	"github.com/starfederation/datastar-go/datastar/client"
+)
+
+// Recorder holds the events emitted by a Datastar handler.
+type Recorder struct {
+	t testing.TB
+
+	// Response is the raw recorded response.
+	Response *httptest.ResponseRecorder
+	// Events holds every decoded event in the order it was sent.
+	Events []client.Event
+
+	elements     []client.PatchElementsEvent
+	signals      map[string]any
+	scripts      []Script
+	redirects    []string
+	customEvents []CustomEvent
+}
+
+// Record serves r with h and decodes the resulting event stream.
+// It fails the test if the stream cannot be decoded.
+func Record(t testing.TB, h http.Handler, r *http.Request) *Recorder {
+	t.Helper()
+
+	rec := &Recorder{
+		t:        t,
+		Response: httptest.NewRecorder(),
+		signals:  map[string]any{},
+	}
+	h.ServeHTTP(rec.Response, r)
+
+	d := client.NewDecoder(strings.NewReader(rec.Response.Body.String()))
+	for {
+		evt, err := d.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("datastartest: failed to decode event stream: %v", err)
+		}
+		rec.Events = append(rec.Events, evt)
+		if err := rec.apply(evt); err != nil {
+			t.Fatalf("datastartest: %v", err)
+		}
+	}
+
+	return rec
+}
+
+func (rec *Recorder) apply(evt client.Event) error {
+	switch e := evt.(type) {
+	case client.PatchSignalsEvent:
+		var patch map[string]any
+		if err := json.Unmarshal(e.Signals, &patch); err != nil {
+			return fmt.Errorf("failed to unmarshal signals %q: %w", e.Signals, err)
+		}
+		mergeSignals(rec.signals, patch, e.OnlyIfMissing)
+	case client.PatchElementsEvent:
+		script, ok := parseScript(e)
+		if !ok {
+			rec.elements = append(rec.elements, e)
+			return nil
+		}
+		rec.scripts = append(rec.scripts, script)
+		if url, ok := parseRedirect(script.Contents); ok {
+			rec.redirects = append(rec.redirects, url)
+		}
+		if ce, ok := parseCustomEvent(script.Contents); ok {
+			rec.customEvents = append(rec.customEvents, ce)
+		}
+	}
+	return nil
+}
+
+// Elements returns every elements patch that is not a script execution.
+func (rec *Recorder) Elements() []client.PatchElementsEvent {
+	return rec.elements
+}
+
+// Signals returns the client signals state after applying every signals
+// patch in order, following Datastar's merge semantics.
+func (rec *Recorder) Signals() map[string]any {
+	return rec.signals
+}
+
+// Signal returns the value of the signal at a dot-separated path, such as "user.name".
+func (rec *Recorder) Signal(path string) (any, bool) {
+	var current any = rec.signals
+	for key := range strings.SplitSeq(path, ".") {
+		obj, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if current, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// Scripts returns every script sent with [datastar.ServerSentEventGenerator.ExecuteScript].
+func (rec *Recorder) Scripts() []Script {
+	return rec.scripts
+}
+
+// Redirects returns every URL the handler redirected to.
+func (rec *Recorder) Redirects() []string {
+	return rec.redirects
+}
+
+// CustomEvents returns every event sent with [datastar.ServerSentEventGenerator.DispatchCustomEvent].
+func (rec *Recorder) CustomEvents() []CustomEvent {
+	return rec.customEvents
+}
+
+// AssertPatchedSelector fails the test unless an elements patch targeted selector.
+func (rec *Recorder) AssertPatchedSelector(selector string) {
+	rec.t.Helper()
+	for _, e := range rec.elements {
+		if e.Selector == selector {
+			return
+		}
+	}
+	rec.t.Errorf("datastartest: expected elements patch with selector %q, got %v", selector, rec.selectors())
+}
+
+// AssertPatchedElements fails the test unless an elements patch with the given
+// selector and mode contained the substring.
+func (rec *Recorder) AssertPatchedElements(selector string, mode datastar.ElementPatchMode, contains string) {
+	rec.t.Helper()
+	for _, e := range rec.elements {
+		if e.Selector == selector && e.Mode == mode && strings.Contains(e.Elements, contains) {
+			return
+		}
+	}
+	rec.t.Errorf("datastartest: expected %s elements patch with selector %q containing %q", mode, selector, contains)
+}
+
+// AssertSignal fails the test unless the signal at path equals want once
+// both are compared as JSON values.
+func (rec *Recorder) AssertSignal(path string, want any) {
+	rec.t.Helper()
+	got, ok := rec.Signal(path)
+	if !ok {
+		rec.t.Errorf("datastartest: expected signal %q to be set", path)
+		return
+	}
+	wantJSON, err := normalizeJSON(want)
+	if err != nil {
+		rec.t.Fatalf("datastartest: failed to marshal expected signal %q: %v", path, err)
+	}
+	if !reflect.DeepEqual(got, wantJSON) {
+		rec.t.Errorf("datastartest: expected signal %q to be %v, got %v", path, wantJSON, got)
+	}
+}
+
+// AssertNoSignal fails the test if the signal at path is set.
+func (rec *Recorder) AssertNoSignal(path string) {
+	rec.t.Helper()
+	if got, ok := rec.Signal(path); ok {
+		rec.t.Errorf("datastartest: expected signal %q to be unset, got %v", path, got)
+	}
+}
+
+// AssertRedirectedTo fails the test unless the handler redirected to url.
+func (rec *Recorder) AssertRedirectedTo(url string) {
+	rec.t.Helper()
+	for _, r := range rec.redirects {
+		if r == url {
+			return
+		}
+	}
+	rec.t.Errorf("datastartest: expected redirect to %q, got %v", url, rec.redirects)
+}
+
+// AssertScriptContains fails the test unless an executed script contains the substring.
+func (rec *Recorder) AssertScriptContains(contains string) {
+	rec.t.Helper()
+	for _, s := range rec.scripts {
+		if strings.Contains(s.Contents, contains) {
+			return
+		}
+	}
+	rec.t.Errorf("datastartest: expected a script containing %q", contains)
+}
+
+// AssertCustomEvent fails the test unless a custom event with the given name was dispatched.
+func (rec *Recorder) AssertCustomEvent(name string) {
+	rec.t.Helper()
+	for _, ce := range rec.customEvents {
+		if ce.Name == name {
+			return
+		}
+	}
+	rec.t.Errorf("datastartest: expected custom event %q to be dispatched", name)
+}
+
+func (rec *Recorder) selectors() []string {
+	selectors := make([]string, 0, len(rec.elements))
+	for _, e := range rec.elements {
+		selectors = append(selectors, e.Selector)
+	}
+	return selectors
+}
+
+// normalizeJSON round-trips v through JSON so it compares equal to decoded signals.
+func normalizeJSON(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// mergeSignals applies a JSON merge patch to the signals state.
+// A null value removes the signal. With onlyIfMissing, existing
+// signals are left untouched.
+func mergeSignals(target, patch map[string]any, onlyIfMissing bool) {
+	for key, value := range patch {
+		existing, exists := target[key]
+
+		if nested, ok := value.(map[string]any); ok {
+			existingObj, ok := existing.(map[string]any)
+			if !ok {
+				if exists && onlyIfMissing {
+					continue
+				}
+				existingObj = map[string]any{}
+				target[key] = existingObj
+			}
+			mergeSignals(existingObj, nested, onlyIfMissing)
+			continue
+		}
+
+		if onlyIfMissing && exists {
+			continue
+		}
+		if value == nil {
+			delete(target, key)
+			continue
+		}
+		target[key] = value
+	}
+}
@@ -0,0 +1,81 @@
+package datastartest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+func TestRecorder(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sse := datastar.NewSSE(w, r)
+		sse.PatchElements(`<div id="list">one</div>`, datastar.WithSelectorID("list"), datastar.WithModeInner())
+		sse.MarshalAndPatchSignals(map[string]any{"user": map[string]any{"name": "alice", "age": 30}, "draft": "x"})
+		sse.PatchSignals([]byte(`{"user":{"name":"bob"},"draft":null}`))
+		sse.MarshalAndPatchSignalsIfMissing(map[string]any{"user": map[string]any{"name": "carol"}, "count": 1})
+		sse.DispatchCustomEvent("saved", map[string]int{"id": 7}, datastar.WithDispatchCustomEventSelector("#list"))
+		sse.ConsoleLog("done")
+		sse.Redirect("/next?a=1")
+	})
+
+	rec := Record(t, h, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	rec.AssertPatchedSelector("#list")
+	rec.AssertPatchedElements("#list", datastar.ElementPatchModeInner, "one")
+	rec.AssertSignal("user.name", "bob")
+	rec.AssertSignal("user.age", 30)
+	rec.AssertSignal("count", 1)
+	rec.AssertNoSignal("draft")
+	rec.AssertRedirectedTo("/next?a=1")
+	rec.AssertScriptContains("console.log")
+	rec.AssertCustomEvent("saved")
+
+	if got := len(rec.Elements()); got != 1 {
+		t.Errorf("Expected 1 elements patch, got %d", got)
+	}
+	if got := len(rec.Scripts()); got != 3 {
+		t.Errorf("Expected 3 scripts, got %d", got)
+	}
+	ce := rec.CustomEvents()[0]
+	if ce.Selector != "#list" || string(ce.Detail) != `{"id":7}` {
+		t.Errorf("Unexpected custom event: %+v", ce)
+	}
+	if !rec.Scripts()[0].AutoRemove {
+		t.Error("Expected script to be auto removed")
+	}
+}
+
+func TestRecorderEscapedScript(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(datastar.ContextWithScriptNonce(r.Context(), "n0nce"))
+		sse := datastar.NewSSE(w, r)
+		sse.ExecuteScript(`document.body.innerHTML = "</script><!-- -->"`)
+	})
+
+	rec := Record(t, h, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	rec.AssertScriptContains(`"</script><!-- -->"`)
+	if got := rec.Scripts()[0].Attributes["nonce"]; got != "n0nce" {
+		t.Errorf("Expected nonce n0nce, got %q", got)
+	}
+}
+
+func TestRecorderNavigate(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sse := datastar.NewSSE(w, r)
+		sse.Navigate("/a")
+		sse.Navigate("/b", datastar.WithNavigateReplace(), datastar.WithNavigateDelay(time.Second))
+		sse.Navigate("/c", datastar.WithNavigateNewTab())
+	})
+
+	rec := Record(t, h, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	rec.AssertRedirectedTo("/a")
+	rec.AssertRedirectedTo("/b")
+	if got := rec.Redirects(); len(got) != 2 {
+		t.Errorf("Expected 2 redirects, got %q", got)
+	}
+}
@@ -0,0 +1,89 @@
+package datastartest
+
+import (
+	"encoding/json"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/starfederation/datastar-go/datastar"
+	"github.com/starfederation/datastar-go/datastar/client"
+	"github.com/starfederation/datastar-go/datastar/internal/scripts"
+)
+
+// Script is a script executed with [datastar.ServerSentEventGenerator.ExecuteScript].
+type Script struct {
+	Contents   string
+	Attributes map[string]string
+	AutoRemove bool
+}
+
+// CustomEvent is an event dispatched with [datastar.ServerSentEventGenerator.DispatchCustomEvent].
+type CustomEvent struct {
+	Name string
+	// Selector is the CSS selector of the dispatching elements, or "document".
+	Selector string
+	// Detail is the JSON-encoded event detail.
+	Detail json.RawMessage
+}
+
+const autoRemoveAttribute = "data-effect"
+
+var (
+	scriptAttributeRegex = regexp.MustCompile(`([^\s="]+)(?:="([^"]*)")?`)
+	escapedScriptRegex   = regexp.MustCompile(`(?i)<\\/script|<\\!--`)
+)
+
+// parseScript recognizes the elements patch emitted by ExecuteScript.
+func parseScript(e client.PatchElementsEvent) (Script, bool) {
+	if e.Selector != "body" || e.Mode != datastar.ElementPatchModeAppend {
+		return Script{}, false
+	}
+	if !strings.HasPrefix(e.Elements, "<script") || !strings.HasSuffix(e.Elements, "</script>") {
+		return Script{}, false
+	}
+
+	openTag, contents, ok := strings.Cut(strings.TrimPrefix(e.Elements, "<script"), ">")
+	if !ok {
+		return Script{}, false
+	}
+
+	script := Script{
+		Contents:   unescapeScriptContents(strings.TrimSuffix(contents, "</script>")),
+		Attributes: map[string]string{},
+	}
+	for _, m := range scriptAttributeRegex.FindAllStringSubmatch(openTag, -1) {
+		if m[1] == autoRemoveAttribute {
+			script.AutoRemove = true
+			continue
+		}
+		script.Attributes[m[1]] = html.UnescapeString(m[2])
+	}
+	return script, true
+}
+
+// unescapeScriptContents reverts the escaping ExecuteScript applies to
+// `</script` and `<!--`, returning the contents as they were passed to it.
+func unescapeScriptContents(contents string) string {
+	return escapedScriptRegex.ReplaceAllStringFunc(contents, func(m string) string {
+		return strings.Replace(m, `\`, "", 1)
+	})
+}
+
+// parseRedirect extracts the target URL from a script sent by Redirect or Navigate.
+func parseRedirect(contents string) (string, bool) {
+	return scripts.ParseNavigation(contents)
+}
+
+// parseCustomEvent extracts the event from a script sent by DispatchCustomEvent.
+func parseCustomEvent(contents string) (CustomEvent, bool) {
+	e, ok := scripts.ParseCustomEvent(contents)
+	if !ok {
+		return CustomEvent{}, false
+	}
+	ce := CustomEvent{Name: e.Name, Selector: e.Selector, Detail: json.RawMessage(e.Detail)}
+	if ce.Selector == "" {
+		ce.Selector = "document"
+	}
+	return ce, true
+}
@@ -157,13 +157,17 @@ type TemplComponent interface {
 }
 
 // PatchElementTempl is a convenience adaptor of [sse.PatchElements] for [TemplComponent].
+// The component renders straight into the event without an intermediate copy.
 func (sse *ServerSentEventGenerator) PatchElementTempl(c TemplComponent, opts ...PatchElementOption) error {
-	buf := bytebufferpool.Get()
-	defer bytebufferpool.Put(buf)
-	if err := c.Render(sse.Context(), buf); err != nil {
-		return fmt.Errorf("failed to patch element: %w", err)
-	}
-	if err := sse.PatchElements(buf.String(), opts...); err != nil {
+	evt := patchElementsEvent(opts)
+	if err := sse.sendWith(&evt, func(buf *bytebufferpool.ByteBuffer) error {
+		lines := dataLineWriter{buf: buf, literal: ElementsDatalineLiteral}
+		if err := c.Render(sse.Context(), &lines); err != nil {
+			return fmt.Errorf("failed to render: %w", err)
+		}
+		lines.close()
+		return nil
+	}); err != nil {
 		return fmt.Errorf("failed to patch element: %w", err)
 	}
 	return nil
@@ -179,13 +183,17 @@ type GoStarElementRenderer interface {
 }
 
 // PatchElementGostar is a convenience adaptor of [sse.PatchElements] for [GoStarElementRenderer].
+// The element renders straight into the event without an intermediate copy.
 func (sse *ServerSentEventGenerator) PatchElementGostar(child GoStarElementRenderer, opts ...PatchElementOption) error {
-	buf := bytebufferpool.Get()
-	defer bytebufferpool.Put(buf)
-	if err := child.Render(buf); err != nil {
-		return fmt.Errorf("failed to render: %w", err)
-	}
-	if err := sse.PatchElements(buf.String(), opts...); err != nil {
+	evt := patchElementsEvent(opts)
+	if err := sse.sendWith(&evt, func(buf *bytebufferpool.ByteBuffer) error {
+		lines := dataLineWriter{buf: buf, literal: ElementsDatalineLiteral}
+		if err := child.Render(&lines); err != nil {
+			return fmt.Errorf("failed to render: %w", err)
+		}
+		lines.close()
+		return nil
+	}); err != nil {
 		return fmt.Errorf("failed to patch element: %w", err)
 	}
 	return nil
@@ -0,0 +1,173 @@
+package datastar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"sync"
+)
+
+// TemplateContextFunc is the name of the [html/template] function that returns
+// the stream context while a template is rendered by [sse.PatchElementTemplate]
+// or a [TemplateSet]. It makes request-scoped values available to template
+// funcs, the way [sse.PatchElementTempl] passes the context to [TemplComponent]s:
+//
+//	{{ with currentUser datastarContext }}{{ .Name }}{{ end }}
+const TemplateContextFunc = "datastarContext"
+
+// ErrNoTemplateContext is returned when a template calls the [TemplateContextFunc]
+// while it is executed directly, which has no request context to return.
+var ErrNoTemplateContext = errors.New(TemplateContextFunc + " is only available to templates rendered by datastar")
+
+// TemplateFuncs returns the functions provided by this package to templates.
+// They must be registered with [template.Template.Funcs] before parsing
+// templates that use them. [ParseTemplatesFS] registers them automatically.
+// When a template is executed directly, the [TemplateContextFunc] fails the
+// render with [ErrNoTemplateContext].
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		TemplateContextFunc: func() (context.Context, error) {
+			return nil, ErrNoTemplateContext
+		},
+	}
+}
+
+// templateInstance is a clone of a template whose [TemplateContextFunc]
+// returns the context of the render currently using it.
+type templateInstance struct {
+	t   *template.Template
+	ctx context.Context
+}
+
+// newTemplateInstance clones t for rendering with a context. It fails if
+// t cannot be cloned because it has already been executed.
+func newTemplateInstance(t *template.Template) (*templateInstance, error) {
+	clone, err := t.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone templates: %w", err)
+	}
+	inst := &templateInstance{}
+	inst.t = clone.Funcs(template.FuncMap{
+		TemplateContextFunc: func() context.Context { return inst.ctx },
+	})
+	return inst, nil
+}
+
+// templatePool reuses the clones of one template across renders, so
+// html/template escapes each clone once instead of on every render.
+type templatePool struct {
+	instances sync.Pool
+}
+
+// get returns an instance of t rendering with ctx.
+func (p *templatePool) get(t *template.Template, ctx context.Context) (*templateInstance, error) {
+	inst, ok := p.instances.Get().(*templateInstance)
+	if !ok {
+		var err error
+		if inst, err = newTemplateInstance(t); err != nil {
+			return nil, err
+		}
+	}
+	inst.ctx = ctx
+	return inst, nil
+}
+
+func (p *templatePool) put(inst *templateInstance) {
+	inst.ctx = nil
+	p.instances.Put(inst)
+}
+
+// PatchElementTemplate is a convenience adaptor of [sse.PatchElements] for [html/template].
+// It renders the template with the given name into a single elements patch,
+// with [sse.Context] available through the [TemplateContextFunc].
+//
+// The context is provided by a clone of t, which html/template escapes again
+// on every call. It fails if t has already been executed directly, since it
+// can no longer be cloned. To render the same templates many times, or both
+// as full pages and as patches, use a [TemplateSet], which reuses its clones.
+func (sse *ServerSentEventGenerator) PatchElementTemplate(t *template.Template, name string, data any, opts ...PatchElementOption) error {
+	inst, err := newTemplateInstance(t)
+	if err != nil {
+		return err
+	}
+	inst.ctx = sse.Context()
+	return sse.patchElementTemplate(inst.t, name, data, opts)
+}
+
+func (sse *ServerSentEventGenerator) patchElementTemplate(t *template.Template, name string, data any, opts []PatchElementOption) error {
+	w := sse.newElementsWriter(opts)
+	if err := t.ExecuteTemplate(w, name, data); err != nil {
+		w.discard()
+		return fmt.Errorf("failed to render template %q: %w", name, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to patch element: %w", err)
+	}
+	return nil
+}
+
+// TemplateSet is a set of [html/template] templates, such as a page and its
+// partials, that can be rendered as a full page and re-rendered by name as
+// elements patches. Every render sees its own request context through the
+// [TemplateContextFunc]. It is safe for concurrent use.
+type TemplateSet struct {
+	// base is never executed so it can always be cloned
+	base *template.Template
+	pool templatePool
+}
+
+// NewTemplateSet returns a [TemplateSet] of t and its associated templates,
+// which must have been parsed with [TemplateFuncs] to use the
+// [TemplateContextFunc]. The set keeps a clone of t, so t can still be
+// changed or executed afterwards. It fails if t has already been executed.
+func NewTemplateSet(t *template.Template) (*TemplateSet, error) {
+	base, err := t.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone templates: %w", err)
+	}
+	return &TemplateSet{base: base}, nil
+}
+
+// ParseTemplatesFS parses the templates matching the patterns in fsys into a
+// [TemplateSet], as [template.ParseFS] does. The funcs are registered together
+// with [TemplateFuncs] before parsing and may be nil.
+func ParseTemplatesFS(fsys fs.FS, funcs template.FuncMap, patterns ...string) (*TemplateSet, error) {
+	t, err := template.New("").Funcs(TemplateFuncs()).Funcs(funcs).ParseFS(fsys, patterns...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse templates: %w", err)
+	}
+	return &TemplateSet{base: t}, nil
+}
+
+// Lookup reports whether the set has a template with the given name.
+func (s *TemplateSet) Lookup(name string) bool {
+	return s.base.Lookup(name) != nil
+}
+
+// Execute renders the template with the given name to w, for example as a full page.
+func (s *TemplateSet) Execute(ctx context.Context, w io.Writer, name string, data any) error {
+	inst, err := s.pool.get(s.base, ctx)
+	if err != nil {
+		return err
+	}
+	defer s.pool.put(inst)
+	if err := inst.t.ExecuteTemplate(w, name, data); err != nil {
+		return fmt.Errorf("failed to render template %q: %w", name, err)
+	}
+	return nil
+}
+
+// PatchElementTemplateSet renders the template with the given name from the set
+// into a single elements patch, with [sse.Context] available through the
+// [TemplateContextFunc].
+func (sse *ServerSentEventGenerator) PatchElementTemplateSet(s *TemplateSet, name string, data any, opts ...PatchElementOption) error {
+	inst, err := s.pool.get(s.base, sse.Context())
+	if err != nil {
+		return err
+	}
+	defer s.pool.put(inst)
+	return sse.patchElementTemplate(inst.t, name, data, opts)
+}
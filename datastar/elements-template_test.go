@@ -0,0 +1,159 @@
+package datastar
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+type templateUserKey struct{}
+
+func TestPatchElementTemplateSet(t *testing.T) {
+	fsys := fstest.MapFS{
+		"page.html": {Data: []byte(`{{ define "page" }}<main>{{ template "greeting" . }}</main>{{ end }}`)},
+		"greeting.html": {Data: []byte(`{{ define "greeting" }}<p id="greeting">
+	Hello {{ .Name }} from {{ currentUser datastarContext }}
+</p>{{ end }}`)},
+	}
+	set, err := ParseTemplatesFS(fsys, template.FuncMap{
+		"currentUser": func(ctx context.Context) string {
+			user, _ := ctx.Value(templateUserKey{}).(string)
+			return user
+		},
+	}, "*.html")
+	if err != nil {
+		t.Fatalf("Expected no error parsing templates, got: %v", err)
+	}
+
+	// render the full page first, as the initial request would
+	var page strings.Builder
+	if err := set.Execute(context.WithValue(context.Background(), templateUserKey{}, "page"), &page, "page", map[string]string{"Name": "<b>"}); err != nil {
+		t.Fatalf("Expected no error rendering the page, got: %v", err)
+	}
+	if !strings.Contains(page.String(), "Hello &lt;b&gt; from page") {
+		t.Errorf("Expected escaped page with context value, got: %q", page.String())
+	}
+
+	ctx := context.WithValue(context.Background(), templateUserKey{}, "bob")
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil).WithContext(ctx))
+	if err := sse.PatchElementTemplateSet(set, "greeting", map[string]string{"Name": "alice"}); err != nil {
+		t.Fatalf("Expected no error patching, got: %v", err)
+	}
+
+	want := "event: datastar-patch-elements\n" +
+		"data: elements <p id=\"greeting\">\n" +
+		"data: elements \tHello alice from bob\n" +
+		"data: elements </p>\n" +
+		"\n\n"
+	if w.Body.String() != want {
+		t.Errorf("Expected %q, got: %q", want, w.Body.String())
+	}
+}
+
+func TestPatchElementTemplate(t *testing.T) {
+	tmpl := template.Must(template.New("").Funcs(TemplateFuncs()).Parse(`{{ define "item" }}<li>{{ . }}</li>{{ end }}`))
+
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+	if err := sse.PatchElementTemplate(tmpl, "item", "one", WithSelectorID("list"), WithModeAppend()); err != nil {
+		t.Fatalf("Expected no error patching, got: %v", err)
+	}
+	if !strings.Contains(w.Body.String(), "data: elements <li>one</li>\n") {
+		t.Errorf("Expected rendered item, got: %q", w.Body.String())
+	}
+
+	if err := sse.PatchElementTemplate(tmpl, "missing", nil); err == nil {
+		t.Error("Expected an error for an undefined template")
+	}
+}
+
+func TestPatchElementTemplateContext(t *testing.T) {
+	parse := func() *template.Template {
+		return template.Must(template.New("").Funcs(TemplateFuncs()).Funcs(template.FuncMap{
+			"currentUser": func(ctx context.Context) string {
+				user, _ := ctx.Value(templateUserKey{}).(string)
+				return user
+			},
+		}).Parse(`{{ define "user" }}<p>{{ currentUser datastarContext }}</p>{{ end }}`))
+	}
+
+	tmpl := parse()
+	var wg sync.WaitGroup
+	for _, user := range []string{"alice", "bob", "carol", "dave"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := context.WithValue(context.Background(), templateUserKey{}, user)
+			w := httptest.NewRecorder()
+			sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil).WithContext(ctx))
+			for range 10 {
+				if err := sse.PatchElementTemplate(tmpl, "user", nil); err != nil {
+					t.Errorf("Expected no error patching, got: %v", err)
+				}
+			}
+			if got := strings.Count(w.Body.String(), "<p>"+user+"</p>"); got != 10 {
+				t.Errorf("Expected every patch to see the context of %s, got: %q", user, w.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+
+	// a template set keeps working after the template is rendered as a full page
+	tmpl = parse()
+	set, err := NewTemplateSet(tmpl)
+	if err != nil {
+		t.Fatalf("Expected no error creating the set, got: %v", err)
+	}
+	if err := tmpl.ExecuteTemplate(&strings.Builder{}, "user", nil); !errors.Is(err, ErrNoTemplateContext) {
+		t.Errorf("Expected ErrNoTemplateContext rendering the page, got: %v", err)
+	}
+	ctx := context.WithValue(context.Background(), templateUserKey{}, "erin")
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil).WithContext(ctx))
+	if err := sse.PatchElementTemplateSet(set, "user", nil); err != nil {
+		t.Fatalf("Expected no error patching, got: %v", err)
+	}
+	if !strings.Contains(w.Body.String(), "<p>erin</p>") {
+		t.Errorf("Expected the context of the stream, got: %q", w.Body.String())
+	}
+
+	// the executed template itself can no longer be cloned
+	if _, err := NewTemplateSet(tmpl); err == nil {
+		t.Error("Expected an error creating a set of an executed template")
+	}
+	w = httptest.NewRecorder()
+	sse = NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+	if err := sse.PatchElementTemplate(tmpl, "user", nil); err == nil {
+		t.Error("Expected the clone error")
+	}
+	if strings.Contains(w.Body.String(), "event:") {
+		t.Errorf("Expected no event, got: %q", w.Body.String())
+	}
+}
+
+func TestPatchElementTemplateSetError(t *testing.T) {
+	set, err := ParseTemplatesFS(fstest.MapFS{
+		"item.html": {Data: []byte(`{{ define "item" }}<li>{{ .Name }}</li>{{ end }}`)},
+	}, nil, "*.html")
+	if err != nil {
+		t.Fatalf("Expected no error parsing templates, got: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+	if err := sse.PatchElementTemplateSet(set, "item", 42); err == nil {
+		t.Error("Expected an error rendering a field of an int")
+	}
+	if err := sse.PatchElementTemplateSet(set, "item", map[string]string{"Name": "one"}); err != nil {
+		t.Fatalf("Expected no error patching, got: %v", err)
+	}
+	if strings.Count(w.Body.String(), "event:") != 1 || !strings.Contains(w.Body.String(), "<li>one</li>") {
+		t.Errorf("Expected only the successful patch, got: %q", w.Body.String())
+	}
+}
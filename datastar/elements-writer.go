@@ -0,0 +1,86 @@
+package datastar
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/valyala/bytebufferpool"
+)
+
+// elementsWriter renders into a single elements patch event.
+type elementsWriter struct {
+	sse   *ServerSentEventGenerator
+	evt   serverSentEventData
+	buf   *bytebufferpool.ByteBuffer
+	lines dataLineWriter
+	err   error
+}
+
+// ElementsWriter returns an [io.WriteCloser] that turns everything written to it
+// into a single [EventTypePatchElements] event, which is sent on Close.
+// Any template engine that renders to an [io.Writer] can render straight into
+// the event without buffering its output first.
+//
+// Nothing is sent if Close is never called. Writes after Close fail.
+func (sse *ServerSentEventGenerator) ElementsWriter(opts ...PatchElementOption) io.WriteCloser {
+	return sse.newElementsWriter(opts)
+}
+
+func (sse *ServerSentEventGenerator) newElementsWriter(opts []PatchElementOption) *elementsWriter {
+	w := &elementsWriter{
+		sse: sse,
+		evt: patchElementsEvent(opts),
+		buf: bytebufferpool.Get(),
+	}
+	w.lines = dataLineWriter{buf: w.buf, literal: ElementsDatalineLiteral}
+	if w.err = encodeEventHeader(w.buf, &w.evt); w.err != nil {
+		bytebufferpool.Put(w.buf)
+	}
+	return w
+}
+
+var errElementsWriterClosed = errors.New("elements writer closed")
+
+// Write implements [io.Writer].
+func (w *elementsWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.lines.Write(p)
+}
+
+// WriteString implements [io.StringWriter].
+func (w *elementsWriter) WriteString(s string) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.lines.WriteString(s)
+}
+
+// Close finalizes the event and sends it.
+func (w *elementsWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.err = errElementsWriterClosed
+	defer bytebufferpool.Put(w.buf)
+
+	w.lines.close()
+	if err := encodeEventEnd(w.buf); err != nil {
+		return err
+	}
+	if err := w.sse.sendEncoded(w.buf.B, &w.evt); err != nil {
+		return fmt.Errorf("failed to send elements: %w", err)
+	}
+	return nil
+}
+
+// discard releases the event without sending it.
+func (w *elementsWriter) discard() {
+	if w.err != nil {
+		return
+	}
+	w.err = errElementsWriterClosed
+	bytebufferpool.Put(w.buf)
+}
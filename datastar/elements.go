@@ -2,8 +2,10 @@ package datastar
 
 import (
 	"fmt"
-	"strings"
+	"io"
 	"time"
+
+	"github.com/valyala/bytebufferpool"
 )
 
 // patchElementOptions holds the configuration data for [PatchElementOption]s used
@@ -82,6 +84,74 @@ func WithRetryDuration(retryDuration time.Duration) PatchElementOption {
 
 // PatchElements sends HTML elements to the client to update the DOM tree with.
 func (sse *ServerSentEventGenerator) PatchElements(elements string, opts ...PatchElementOption) error {
+	evt := patchElementsEvent(opts)
+	if err := sse.sendWith(&evt, func(buf *bytebufferpool.ByteBuffer) error {
+		lines := dataLineWriter{buf: buf, literal: ElementsDatalineLiteral}
+		lines.WriteString(elements)
+		lines.close()
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to send elements: %w", err)
+	}
+
+	return nil
+}
+
+// PatchElementsBytes is the []byte variant of [ServerSentEventGenerator.PatchElements].
+// The elements are written line by line straight into the event without
+// being converted to a string or split into lines first.
+func (sse *ServerSentEventGenerator) PatchElementsBytes(elements []byte, opts ...PatchElementOption) error {
+	evt := patchElementsEvent(opts)
+	if err := sse.sendWith(&evt, func(buf *bytebufferpool.ByteBuffer) error {
+		lines := dataLineWriter{buf: buf, literal: ElementsDatalineLiteral}
+		lines.Write(elements)
+		lines.close()
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to send elements: %w", err)
+	}
+
+	return nil
+}
+
+// PatchElementsReader is the [io.Reader] variant of [ServerSentEventGenerator.PatchElements].
+// The elements are read until [io.EOF] and written line by line straight into the event.
+// Nothing is sent if reading fails.
+func (sse *ServerSentEventGenerator) PatchElementsReader(r io.Reader, opts ...PatchElementOption) error {
+	evt := patchElementsEvent(opts)
+	if err := sse.sendWith(&evt, func(buf *bytebufferpool.ByteBuffer) error {
+		lines := dataLineWriter{buf: buf, literal: ElementsDatalineLiteral}
+		if _, err := io.Copy(&lines, r); err != nil {
+			return fmt.Errorf("failed to read elements: %w", err)
+		}
+		lines.close()
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to send elements: %w", err)
+	}
+
+	return nil
+}
+
+// NewPatchElementsEvent encodes an [EventTypePatchElements] event once so it
+// can be sent to many streams with [ServerSentEventGenerator.SendEvent].
+func NewPatchElementsEvent(elements string, opts ...PatchElementOption) (Event, error) {
+	evt := patchElementsEvent(opts)
+	e, err := newEventWith(&evt, func(buf *bytebufferpool.ByteBuffer) error {
+		lines := dataLineWriter{buf: buf, literal: ElementsDatalineLiteral}
+		lines.WriteString(elements)
+		lines.close()
+		return nil
+	})
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to encode elements: %w", err)
+	}
+	return e, nil
+}
+
+// patchElementsEvent translates the options of an elements patch into an event
+// holding every data line except the elements themselves.
+func patchElementsEvent(opts []PatchElementOption) serverSentEventData {
 	options := &patchElementOptions{
 		EventID:       "",
 		RetryDuration: DefaultSseRetryDuration,
@@ -92,12 +162,13 @@ func (sse *ServerSentEventGenerator) PatchElements(elements string, opts ...Patc
 		opt(options)
 	}
 
-	sendOptions := make([]SSEEventOption, 0, 2)
-	if options.EventID != "" {
-		sendOptions = append(sendOptions, WithSSEEventId(options.EventID))
+	evt := serverSentEventData{
+		Type:          EventTypePatchElements,
+		EventID:       options.EventID,
+		RetryDuration: DefaultSseRetryDuration,
 	}
 	if options.RetryDuration > 0 {
-		sendOptions = append(sendOptions, WithSSERetryDuration(options.RetryDuration))
+		evt.RetryDuration = options.RetryDuration
 	}
 
 	dataRows := make([]string, 0, 4)
@@ -113,21 +184,7 @@ func (sse *ServerSentEventGenerator) PatchElements(elements string, opts ...Patc
 	if options.UseViewTransitions {
 		dataRows = append(dataRows, UseViewTransitionDatalineLiteral+"true")
 	}
+	evt.Data = dataRows
 
-	if elements != "" {
-		parts := strings.Split(elements, "\n")
-		for _, part := range parts {
-			dataRows = append(dataRows, ElementsDatalineLiteral+part)
-		}
-	}
-
-	if err := sse.Send(
-		EventTypePatchElements,
-		dataRows,
-		sendOptions...,
-	); err != nil {
-		return fmt.Errorf("failed to send elements: %w", err)
-	}
-
-	return nil
+	return evt
 }
@@ -1,6 +1,16 @@
 package datastar
 
-import "testing"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
 
 func TestAllValidElementMergeTypes(t *testing.T) {
 	var err error
@@ -18,3 +28,136 @@ func TestAllValidElementMergeTypes(t *testing.T) {
 		t.Errorf("Expected a fake type to be an invalid element merge type, but it was accepted")
 	}
 }
+
+func TestPatchElementsVariants(t *testing.T) {
+	const elements = "<div id=\"a\">\n\t<p>one</p>\n</div>\n"
+	const want = "event: datastar-patch-elements\n" +
+		"data: selector #a\n" +
+		"data: elements <div id=\"a\">\n" +
+		"data: elements \t<p>one</p>\n" +
+		"data: elements </div>\n" +
+		"data: elements \n" +
+		"\n\n"
+
+	send := map[string]func(sse *ServerSentEventGenerator) error{
+		"string": func(sse *ServerSentEventGenerator) error {
+			return sse.PatchElements(elements, WithSelectorID("a"))
+		},
+		"bytes": func(sse *ServerSentEventGenerator) error {
+			return sse.PatchElementsBytes([]byte(elements), WithSelectorID("a"))
+		},
+		"reader": func(sse *ServerSentEventGenerator) error {
+			return sse.PatchElementsReader(iotest.OneByteReader(strings.NewReader(elements)), WithSelectorID("a"))
+		},
+	}
+	for name, fn := range send {
+		w := httptest.NewRecorder()
+		if err := fn(NewSSE(w, httptest.NewRequest("GET", "/test", nil))); err != nil {
+			t.Fatalf("%s: expected no error when patching, got: %v", name, err)
+		}
+		if w.Body.String() != want {
+			t.Errorf("%s: expected %q, got: %q", name, want, w.Body.String())
+		}
+	}
+}
+
+func TestPatchElementsReaderError(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+	if err := sse.PatchElementsReader(iotest.ErrReader(errors.New("boom"))); err == nil {
+		t.Error("Expected an error when the reader fails")
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected nothing to be sent, got: %q", w.Body.String())
+	}
+}
+
+// largeTable renders a multi-hundred-KB table like the ones pushed by dashboards.
+func largeTable() string {
+	var sb strings.Builder
+	sb.WriteString("<table id=\"metrics\">\n")
+	for i := range 5000 {
+		fmt.Fprintf(&sb, "<tr><td>%d</td><td>value</td><td>another value</td></tr>\n", i)
+	}
+	sb.WriteString("</table>")
+	return sb.String()
+}
+
+func BenchmarkPatchElements(b *testing.B) {
+	table := largeTable()
+	tableBytes := []byte(table)
+	sse := NewSSE(discardResponseWriter{}, httptest.NewRequest("GET", "/test", nil))
+
+	b.Run("SplitLines", func(b *testing.B) {
+		// the line splitting PatchElements did before writing into the event buffer
+		b.ReportAllocs()
+		b.SetBytes(int64(len(table)))
+		for b.Loop() {
+			parts := strings.Split(table, "\n")
+			dataRows := make([]string, 0, len(parts)+1)
+			dataRows = append(dataRows, SelectorDatalineLiteral+"#metrics")
+			for _, part := range parts {
+				dataRows = append(dataRows, ElementsDatalineLiteral+part)
+			}
+			sse.Send(EventTypePatchElements, dataRows)
+		}
+	})
+	b.Run("String", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(table)))
+		for b.Loop() {
+			sse.PatchElements(table, WithSelectorID("metrics"))
+		}
+	})
+	b.Run("Bytes", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(table)))
+		for b.Loop() {
+			sse.PatchElementsBytes(tableBytes, WithSelectorID("metrics"))
+		}
+	})
+	b.Run("Reader", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(table)))
+		for b.Loop() {
+			sse.PatchElementsReader(bytes.NewReader(tableBytes), WithSelectorID("metrics"))
+		}
+	})
+}
+
+// discardResponseWriter is a flushable [http.ResponseWriter] that drops everything written.
+type discardResponseWriter struct{}
+
+func (discardResponseWriter) Header() http.Header         { return http.Header{} }
+func (discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (discardResponseWriter) WriteHeader(int)             {}
+func (discardResponseWriter) Flush()                      {}
+
+func TestElementsWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+
+	ew := sse.ElementsWriter(WithSelectorID("a"), WithModeInner())
+	fmt.Fprint(ew, "<p>one</p>\n<p>")
+	io.WriteString(ew, "two</p>")
+	if w.Body.Len() != 0 {
+		t.Fatalf("Expected nothing to be sent before Close, got: %q", w.Body.String())
+	}
+	if err := ew.Close(); err != nil {
+		t.Fatalf("Expected no error closing, got: %v", err)
+	}
+
+	want := "event: datastar-patch-elements\n" +
+		"data: selector #a\n" +
+		"data: mode inner\n" +
+		"data: elements <p>one</p>\n" +
+		"data: elements <p>two</p>\n" +
+		"\n\n"
+	if w.Body.String() != want {
+		t.Errorf("Expected %q, got: %q", want, w.Body.String())
+	}
+
+	if _, err := ew.Write([]byte("x")); err == nil {
+		t.Error("Expected an error when writing after Close")
+	}
+}
@@ -0,0 +1,86 @@
+package datastar
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/valyala/bytebufferpool"
+)
+
+// Event is a server-sent event encoded to its wire format once.
+// Sending the same Event to many streams with [ServerSentEventGenerator.SendEvent]
+// skips the per-stream encoding, which makes broadcasts cheap.
+// Compression is stateful per stream, so it is still applied on each send.
+//
+// An Event is immutable and safe for concurrent use.
+type Event struct {
+	eventType EventType
+	id        string
+	key       string
+	encoded   []byte
+}
+
+// NewEvent encodes a server-sent event with the given type and data lines.
+func NewEvent(eventType EventType, dataLines []string, opts ...SSEEventOption) (Event, error) {
+	evt := serverSentEventData{
+		Type:          eventType,
+		Data:          dataLines,
+		RetryDuration: DefaultSseRetryDuration,
+	}
+	for _, opt := range opts {
+		opt(&evt)
+	}
+
+	return newEventWith(&evt, nil)
+}
+
+// newEventWith encodes an event whose trailing data lines are written by body.
+func newEventWith(evt *serverSentEventData, body func(*bytebufferpool.ByteBuffer) error) (Event, error) {
+	buf := bytebufferpool.Get()
+	defer bytebufferpool.Put(buf)
+	if err := encodeEvent(buf, evt, body); err != nil {
+		return Event{}, err
+	}
+
+	return Event{
+		eventType: evt.Type,
+		id:        evt.EventID,
+		key:       coalesceKey(evt),
+		encoded:   bytes.Clone(buf.B),
+	}, nil
+}
+
+// Type returns the event type.
+func (e Event) Type() EventType {
+	return e.eventType
+}
+
+// Bytes returns the encoded event. The slice must not be modified.
+func (e Event) Bytes() []byte {
+	return e.encoded
+}
+
+// SendEvent emits a pre-encoded event to the client. Method is safe for
+// concurrent use.
+func (sse *ServerSentEventGenerator) SendEvent(evt Event) error {
+	if len(evt.encoded) == 0 {
+		return fmt.Errorf("event is empty")
+	}
+
+	// Check if context is cancelled before attempting to send
+	if err := sse.ctx.Err(); err != nil {
+		return fmt.Errorf("context cancelled: %w", err)
+	}
+
+	sse.mu.Lock()
+	defer sse.mu.Unlock()
+
+	if sse.closed {
+		return errStreamClosed
+	}
+	if sse.replayErr != nil {
+		return sse.replayErr
+	}
+
+	return sse.emitLocked(evt.encoded, evt.id, evt.key, true)
+}
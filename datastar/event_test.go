@@ -0,0 +1,70 @@
+package datastar
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSendEventMatchesPatchElements(t *testing.T) {
+	opts := []PatchElementOption{WithSelectorID("a"), WithModeInner(), WithPatchElementsEventID("1")}
+
+	direct := httptest.NewRecorder()
+	if err := NewSSE(direct, httptest.NewRequest("GET", "/test", nil)).PatchElements("<p>\nhi</p>", opts...); err != nil {
+		t.Fatalf("Expected no error when patching, got: %v", err)
+	}
+
+	evt, err := NewPatchElementsEvent("<p>\nhi</p>", opts...)
+	if err != nil {
+		t.Fatalf("Expected no error encoding, got: %v", err)
+	}
+	for range 2 {
+		cached := httptest.NewRecorder()
+		if err := NewSSE(cached, httptest.NewRequest("GET", "/test", nil)).SendEvent(evt); err != nil {
+			t.Fatalf("Expected no error when sending, got: %v", err)
+		}
+		if cached.Body.String() != direct.Body.String() {
+			t.Errorf("Expected %q, got: %q", direct.Body.String(), cached.Body.String())
+		}
+	}
+}
+
+func TestSendEventAssignsReplayID(t *testing.T) {
+	evt, err := NewPatchSignalsEvent([]byte(`{"a":1}`))
+	if err != nil {
+		t.Fatalf("Expected no error encoding, got: %v", err)
+	}
+
+	store := NewMemoryReplayStore(4)
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil), WithReplay(store))
+	if err := sse.SendEvent(evt); err != nil {
+		t.Fatalf("Expected no error when sending, got: %v", err)
+	}
+
+	want := "id: 1\n" + string(evt.Bytes())
+	if w.Body.String() != want {
+		t.Errorf("Expected %q, got: %q", want, w.Body.String())
+	}
+	if string(evt.Bytes()[:6]) != "event:" {
+		t.Errorf("Expected the shared event bytes to be left untouched, got: %q", evt.Bytes())
+	}
+}
+
+func BenchmarkBroadcastPatchElements(b *testing.B) {
+	elements := "<table>\n" + "<tr><td>cell</td><td>cell</td></tr>\n" + "</table>"
+	sse := NewSSE(httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil))
+
+	b.Run("PatchElements", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			sse.PatchElements(elements)
+		}
+	})
+	b.Run("SendEvent", func(b *testing.B) {
+		evt, _ := NewPatchElementsEvent(elements)
+		b.ReportAllocs()
+		for b.Loop() {
+			sse.SendEvent(evt)
+		}
+	})
+}
@@ -1,12 +1,18 @@
 package datastar
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"html"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 	"time"
+
+	"github.com/starfederation/datastar-go/datastar/internal/scripts"
+	"github.com/starfederation/datastar-go/datastar/js"
 )
 
 // executeScriptOptions hold script options that will be translated to [SSEEventOptions].
@@ -14,7 +20,9 @@ type executeScriptOptions struct {
 	EventID       string
 	AutoRemove    *bool
 	Attributes    []string
+	Nonce         string
 	RetryDuration time.Duration
+	err           error
 }
 
 // ExecuteScriptOption configures script execution event that will be sent to the client.
@@ -56,20 +64,207 @@ func WithExecuteScriptAttributes(attributes ...string) ExecuteScriptOption {
 
 // WithExecuteScriptAttributeKVs is an alternative option for [WithExecuteScriptAttributes].
 // Even parameters are keys, odd parameters are their values.
+// Values are HTML-escaped; invalid attribute names fail the script execution.
 func WithExecuteScriptAttributeKVs(kvs ...string) ExecuteScriptOption {
 	if len(kvs)%2 != 0 {
 		panic("WithExecuteScriptAttributeKVs requires an even number of arguments")
 	}
 	attributes := make([]string, 0, len(kvs)/2)
 	for i := 0; i < len(kvs); i += 2 {
-		attribute := fmt.Sprintf(`%s="%s"`, kvs[i], kvs[i+1])
+		if !isAttributeName(kvs[i]) {
+			return func(o *executeScriptOptions) {
+				o.err = fmt.Errorf("invalid script attribute name %q", kvs[i])
+			}
+		}
+		attribute := fmt.Sprintf(`%s="%s"`, kvs[i], html.EscapeString(kvs[i+1]))
 		attributes = append(attributes, attribute)
 	}
 	return WithExecuteScriptAttributes(attributes...)
 }
 
+// WithExecuteScriptNonce sets the [nonce] attribute of the script element, so it
+// runs on pages whose Content-Security-Policy only allows scripts with that nonce.
+// It overrides the nonce set with [ContextWithScriptNonce]; a nonce attribute
+// set with [WithExecuteScriptAttributes] overrides both.
+//
+// [nonce]: https://developer.mozilla.org/en-US/docs/Web/HTML/Global_attributes/nonce
+func WithExecuteScriptNonce(nonce string) ExecuteScriptOption {
+	return func(o *executeScriptOptions) {
+		o.Nonce = nonce
+	}
+}
+
+type scriptNonceContextKey struct{}
+
+// ContextWithScriptNonce returns a copy of ctx holding the CSP nonce of the page.
+// Streams created by [NewSSE] for a request with this context add the nonce to
+// every script they execute, including those of [ServerSentEventGenerator.Redirect],
+// [ServerSentEventGenerator.DispatchCustomEvent] and [ServerSentEventGenerator.ConsoleLog].
+// Set it from the middleware that generates the Content-Security-Policy header.
+func ContextWithScriptNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, scriptNonceContextKey{}, nonce)
+}
+
+// ScriptNonceFromContext returns the nonce set with [ContextWithScriptNonce],
+// or an empty string.
+func ScriptNonceFromContext(ctx context.Context) string {
+	nonce, _ := ctx.Value(scriptNonceContextKey{}).(string)
+	return nonce
+}
+
+// isAttributeName reports whether name is a valid HTML attribute name.
+func isAttributeName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r <= ' ' || r == 0x7f || strings.ContainsRune(`"'>/=<`, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// escapeScriptContents keeps the contents of a script element from closing it
+// early. `</script` is rewritten to `<\/script`, which JavaScript strings,
+// regular expressions and template literals, as well as JSON strings, read
+// unchanged. Like the HTML tokenizer, it is matched ignoring ASCII case only.
+// In classic scripts, `<!--` is also rewritten to `<\!--`, so a following
+// `<script` cannot keep the element open. Modules and data blocks, such as
+// JSON, are left alone since the backslash would change their meaning;
+// their producers must escape `<` themselves, as [json.Marshal] does.
+func escapeScriptContents(s string, classic bool) string {
+	if !strings.Contains(s, "<") {
+		return s
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(s) + 8)
+	for i := 0; i < len(s); i++ {
+		switch {
+		case hasPrefixFoldASCII(s[i:], "</script"):
+			sb.WriteString(`<\/`)
+			i++
+		case classic && strings.HasPrefix(s[i:], "<!--"):
+			sb.WriteString(`<\!`)
+			i++
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
+
+// hasPrefixFoldASCII reports whether s begins with prefix, which must be
+// lower case, ignoring the case of ASCII letters only.
+func hasPrefixFoldASCII(s, prefix string) bool {
+	if len(s) < len(prefix) {
+		return false
+	}
+	for i := 0; i < len(prefix); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		if c != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// classicScriptTypes are the values of the type attribute of a classic
+// script, after trimming and lowering them.
+var classicScriptTypes = []string{
+	"", "application/ecmascript", "application/javascript", "application/x-ecmascript",
+	"application/x-javascript", "text/ecmascript", "text/javascript", "text/javascript1.0",
+	"text/javascript1.1", "text/javascript1.2", "text/javascript1.3", "text/javascript1.4",
+	"text/javascript1.5", "text/jscript", "text/livescript", "text/x-ecmascript",
+	"text/x-javascript",
+}
+
+// scriptAttribute returns the unescaped value of the named attribute among
+// attributes given as `key="value"` pairs, or false if none has the name.
+func scriptAttribute(attributes []string, name string) (string, bool) {
+	for _, attribute := range attributes {
+		key, value, _ := strings.Cut(attribute, "=")
+		if strings.EqualFold(strings.TrimSpace(key), name) {
+			return html.UnescapeString(strings.Trim(strings.TrimSpace(value), `"'`)), true
+		}
+	}
+	return "", false
+}
+
+// isClassicScript reports whether a script element with the attributes
+// holds classic JavaScript rather than a module or a data block.
+func isClassicScript(attributes []string) bool {
+	typ, _ := scriptAttribute(attributes, "type")
+	return slices.Contains(classicScriptTypes, strings.ToLower(strings.TrimSpace(typ)))
+}
+
 // ExecuteScript runs a script in the client browser by using PatchElements to send a <script> element.
+// The contents are escaped so they cannot close the element early. The script carries the nonce
+// set with [ContextWithScriptNonce] on the stream context, unless [WithExecuteScriptNonce] overrides it.
 func (sse *ServerSentEventGenerator) ExecuteScript(scriptContents string, opts ...ExecuteScriptOption) error {
+	if nonce := ScriptNonceFromContext(sse.ctx); nonce != "" {
+		opts = append([]ExecuteScriptOption{WithExecuteScriptNonce(nonce)}, opts...)
+	}
+	script, patchOpts, err := executeScriptElements(scriptContents, opts)
+	if err != nil {
+		return err
+	}
+	return sse.PatchElements(script, patchOpts...)
+}
+
+// NewExecuteScriptEvent encodes the event sent by [ServerSentEventGenerator.ExecuteScript]
+// once so it can be sent to many streams with [ServerSentEventGenerator.SendEvent].
+// The nonce of a stream context is not applied; use [WithExecuteScriptNonce] instead.
+func NewExecuteScriptEvent(scriptContents string, opts ...ExecuteScriptOption) (Event, error) {
+	script, patchOpts, err := executeScriptElements(scriptContents, opts)
+	if err != nil {
+		return Event{}, err
+	}
+	return NewPatchElementsEvent(script, patchOpts...)
+}
+
+// ExecuteJS runs a JavaScript expression built with the [js] package
+// with [ServerSentEventGenerator.ExecuteScript].
+func (sse *ServerSentEventGenerator) ExecuteJS(expr js.Expr, opts ...ExecuteScriptOption) error {
+	return sse.ExecuteScript(expr.String(), opts...)
+}
+
+// CallJS calls the client function at a dotted path, such as `myApp.refresh`,
+// with the arguments marshaled to JSON with the [JSONCodec] of the stream.
+// The path must consist of JavaScript identifiers; see [js.Ident].
+func (sse *ServerSentEventGenerator) CallJS(fn string, args ...any) error {
+	call, err := sse.callJS(fn, args...)
+	if err != nil {
+		return err
+	}
+	return sse.ExecuteJS(call)
+}
+
+// callJS builds a call of fn with the arguments marshaled by the [JSONCodec].
+func (sse *ServerSentEventGenerator) callJS(fn string, args ...any) (js.Expr, error) {
+	ident, err := js.Ident(fn)
+	if err != nil {
+		return js.Expr{}, err
+	}
+	exprs := make([]js.Expr, len(args))
+	for i, arg := range args {
+		b, err := sse.codec().Marshal(arg)
+		if err != nil {
+			return js.Expr{}, fmt.Errorf("failed to marshal argument %d: %w", i, err)
+		}
+		if exprs[i], err = js.JSON(b); err != nil {
+			return js.Expr{}, fmt.Errorf("failed to marshal argument %d: %w", i, err)
+		}
+	}
+	return js.Call(ident, exprs...), nil
+}
+
+// executeScriptElements builds the <script> element and the options to patch it with.
+func executeScriptElements(scriptContents string, opts []ExecuteScriptOption) (string, []PatchElementOption, error) {
 	options := &executeScriptOptions{
 		RetryDuration: DefaultSseRetryDuration,
 		Attributes:    []string{},
@@ -77,6 +272,9 @@ func (sse *ServerSentEventGenerator) ExecuteScript(scriptContents string, opts .
 	for _, opt := range opts {
 		opt(options)
 	}
+	if options.err != nil {
+		return "", nil, options.err
+	}
 
 	// Build the script element
 	sb := strings.Builder{}
@@ -87,13 +285,19 @@ func (sse *ServerSentEventGenerator) ExecuteScript(scriptContents string, opts .
 		sb.WriteString(attribute)
 	}
 
+	if _, ok := scriptAttribute(options.Attributes, "nonce"); !ok && options.Nonce != "" {
+		sb.WriteString(` nonce="`)
+		sb.WriteString(html.EscapeString(options.Nonce))
+		sb.WriteString(`"`)
+	}
+
 	// Add data-datastar-autoremove attribute if needed
 	if options.AutoRemove == nil || *options.AutoRemove {
 		sb.WriteString(` data-effect="el.remove()"`)
 	}
 
 	sb.WriteString(">")
-	sb.WriteString(scriptContents)
+	sb.WriteString(escapeScriptContents(scriptContents, isClassicScript(options.Attributes)))
 	sb.WriteString("</script>")
 
 	// Use PatchElements to send the script
@@ -108,14 +312,13 @@ func (sse *ServerSentEventGenerator) ExecuteScript(scriptContents string, opts .
 		patchOpts = append(patchOpts, WithRetryDuration(options.RetryDuration))
 	}
 
-	return sse.PatchElements(sb.String(), patchOpts...)
+	return sb.String(), patchOpts, nil
 }
 
 // ConsoleLog is a convenience method for [see.ExecuteScript].
 // It is equivalent to calling [see.ExecuteScript] with [see.WithScript] option set to `console.log(msg)`.
 func (sse *ServerSentEventGenerator) ConsoleLog(msg string, opts ...ExecuteScriptOption) error {
-	call := fmt.Sprintf("console.log(%q)", msg)
-	return sse.ExecuteScript(call, opts...)
+	return sse.ExecuteJS(js.Call(js.MustIdent("console.log"), js.String(msg)), opts...)
 }
 
 // ConsoleLogf is a convenience method for [see.ExecuteScript].
@@ -127,8 +330,7 @@ func (sse *ServerSentEventGenerator) ConsoleLogf(format string, args ...any) err
 // ConsoleError is a convenience method for [see.ExecuteScript].
 // It is equivalent to calling [see.ExecuteScript] with [see.WithScript] option set to `console.error(msg)`.
 func (sse *ServerSentEventGenerator) ConsoleError(err error, opts ...ExecuteScriptOption) error {
-	call := fmt.Sprintf("console.error(%q)", err.Error())
-	return sse.ExecuteScript(call, opts...)
+	return sse.ExecuteJS(js.Call(js.MustIdent("console.error"), js.String(err.Error())), opts...)
 }
 
 // Redirectf is a convenience method for [see.ExecuteScript].
@@ -141,8 +343,7 @@ func (sse *ServerSentEventGenerator) Redirectf(format string, args ...any) error
 // Redirect is a convenience method for [see.ExecuteScript].
 // It sends a redirect event to the client .
 func (sse *ServerSentEventGenerator) Redirect(url string, opts ...ExecuteScriptOption) error {
-	js := fmt.Sprintf("setTimeout(() => window.location.href = %q)", url)
-	return sse.ExecuteScript(js, opts...)
+	return sse.ExecuteScript(scripts.Redirect(url), opts...)
 }
 
 // dispatchCustomEventOptions holds the configuration data
@@ -227,14 +428,14 @@ func WithDispatchCustomEventComposed(composed bool) DispatchCustomEventOption {
 }
 
 // DispatchCustomEvent is a convenience method for dispatching a custom event by executing
-// a client side script via [sse.ExecuteScript] call. The detail struct is marshaled to JSON and
-// passed as a parameter to the event.
+// a client side script via [sse.ExecuteScript] call. The detail struct is marshaled to JSON
+// with the [JSONCodec] of the stream and passed as a parameter to the event.
 func (sse *ServerSentEventGenerator) DispatchCustomEvent(eventName string, detail any, opts ...DispatchCustomEventOption) error {
 	if eventName == "" {
 		return fmt.Errorf("eventName is required")
 	}
 
-	detailsJSON, err := json.Marshal(detail)
+	detailsJSON, err := sse.codec().Marshal(detail)
 	if err != nil {
 		return fmt.Errorf("failed to marshal detail: %w", err)
 	}
@@ -253,34 +454,21 @@ func (sse *ServerSentEventGenerator) DispatchCustomEvent(eventName string, detai
 		opt(&options)
 	}
 
-	elementsJS := `[document]`
-	if options.Selector != "" && options.Selector != defaultSelector {
-		elementsJS = fmt.Sprintf(`document.querySelectorAll(%q)`, options.Selector)
+	selector := options.Selector
+	if selector == defaultSelector {
+		selector = ""
+	}
+	script, err := scripts.CustomEvent(scripts.Event{
+		Name:       eventName,
+		Selector:   selector,
+		Bubbles:    options.Bubbles,
+		Cancelable: options.Cancelable,
+		Composed:   options.Composed,
+		Detail:     detailsJSON,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal detail: %w", err)
 	}
-
-	js := fmt.Sprintf(`
-{
-	const elements = %s
-
-	const event = new CustomEvent(%q, {
-		bubbles: %t,
-		cancelable: %t,
-		composed: %t,
-		detail: %s,
-	});
-
-	elements.forEach((element) => {
-		element.dispatchEvent(event);
-	});
-}
-	`,
-		elementsJS,
-		eventName,
-		options.Bubbles,
-		options.Cancelable,
-		options.Composed,
-		string(detailsJSON),
-	)
 
 	executeOptions := make([]ExecuteScriptOption, 0)
 	if options.EventID != "" {
@@ -290,14 +478,14 @@ func (sse *ServerSentEventGenerator) DispatchCustomEvent(eventName string, detai
 		executeOptions = append(executeOptions, WithExecuteScriptRetryDuration(options.RetryDuration))
 	}
 
-	return sse.ExecuteScript(js, executeOptions...)
+	return sse.ExecuteScript(script, executeOptions...)
 
 }
 
 // ReplaceURL replaces the current URL in the browser's history.
 func (sse *ServerSentEventGenerator) ReplaceURL(u url.URL, opts ...ExecuteScriptOption) error {
-	js := fmt.Sprintf(`window.history.replaceState({}, "", %q)`, u.String())
-	return sse.ExecuteScript(js, opts...)
+	call := js.Call(js.MustIdent("window.history.replaceState"), js.Raw("{}"), js.String(""), js.String(u.String()))
+	return sse.ExecuteJS(call, opts...)
 }
 
 // ReplaceURLQuerystring is a convenience wrapper for [sse.ReplaceURL] that replaces the query
@@ -314,24 +502,18 @@ func (sse *ServerSentEventGenerator) ReplaceURLQuerystring(r *http.Request, valu
 //
 // [speculation rules API]: https://developer.mozilla.org/en-US/docs/Web/API/Speculation_Rules_API
 func (sse *ServerSentEventGenerator) Prefetch(urls ...string) error {
-	wrappedURLs := make([]string, len(urls))
-	for i, url := range urls {
-		wrappedURLs[i] = fmt.Sprintf(`"%s"`, url)
-	}
-	script := fmt.Sprintf(`
-{
-	"prefetch": [
-		{
-			"source": "list",
-			"urls": [
-				%s
-			]
-		}
-	]
-}
-		`, strings.Join(wrappedURLs, ",\n\t\t\t\t"))
+	type rule struct {
+		Source string   `json:"source"`
+		URLs   []string `json:"urls"`
+	}
+	rules, err := json.MarshalIndent(map[string][]rule{
+		"prefetch": {{Source: "list", URLs: urls}},
+	}, "", "\t")
+	if err != nil {
+		return fmt.Errorf("failed to marshal speculation rules: %w", err)
+	}
 	return sse.ExecuteScript(
-		script,
+		string(rules),
 		WithExecuteScriptAutoRemove(false),
 		WithExecuteScriptAttributes(`type="speculationrules"`),
 	)
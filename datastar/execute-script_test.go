@@ -0,0 +1,168 @@
+package datastar
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExecuteScriptEscaping(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+
+	err := sse.ExecuteScript(
+		`console.log("</SCRIPT><script>alert(1)</script> <!-- x")`,
+		WithExecuteScriptAttributeKVs("data-x", `"><img src=x onerror=alert(1)>`),
+		WithExecuteScriptAutoRemove(false),
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	want := `data: elements <script data-x="&#34;&gt;&lt;img src=x onerror=alert(1)&gt;">` +
+		`console.log("<\/SCRIPT><script>alert(1)<\/script> <\!-- x")</script>` + "\n"
+	if !strings.Contains(w.Body.String(), want) {
+		t.Errorf("Expected %q in %q", want, w.Body.String())
+	}
+
+	if err := sse.ExecuteScript("1", WithExecuteScriptAttributeKVs(`a onload="x`, "1")); err == nil {
+		t.Error("Expected an error for an invalid attribute name")
+	}
+}
+
+func TestEscapeScriptContents(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"plain", "plain"},
+		{"a</script>b", `a<\/script>b`},
+		{"</ScRiPt", `<\/ScRiPt`},
+		{"<!--<!--", `<\!--<\!--`},
+		{"</scrip", "</scrip"},
+		{"<\\/script", "<\\/script"},
+	}
+	for _, tt := range tests {
+		if got := escapeScriptContents(tt.in, true); got != tt.want {
+			t.Errorf("escapeScriptContents(%q): Expected %q, got: %q", tt.in, tt.want, got)
+		}
+	}
+	if got := escapeScriptContents(`{"a":"<!--</script>"}`, false); got != `{"a":"<!--<\/script>"}` {
+		t.Errorf("Expected only </script escaped in data blocks, got: %q", got)
+	}
+}
+
+func TestExecuteScriptDataBlock(t *testing.T) {
+	tests := []struct {
+		attributes []string
+		want       string
+	}{
+		{nil, `<\!--`},
+		{[]string{`type="text/javascript"`}, `<\!--`},
+		{[]string{`TYPE=" Text/JavaScript "`}, `<\!--`},
+		{[]string{`type="module"`}, `"<!--"`},
+		{[]string{`type="application/json"`}, `"<!--"`},
+		{[]string{`type="speculationrules"`}, `"<!--"`},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+		if err := sse.ExecuteScript(`"<!--"`, WithExecuteScriptAttributes(tt.attributes...)); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("Expected %s for %v, got: %q", tt.want, tt.attributes, w.Body.String())
+		}
+	}
+}
+
+func TestExecuteScriptNonce(t *testing.T) {
+	r := httptest.NewRequest("GET", "/test", nil)
+	r = r.WithContext(ContextWithScriptNonce(r.Context(), `abc"123`))
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, r)
+
+	if err := sse.Redirect("/next"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := sse.DispatchCustomEvent("ping", nil); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := sse.ConsoleLog("hi", WithExecuteScriptNonce("override")); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	body := w.Body.String()
+	if n := strings.Count(body, `nonce="abc&#34;123"`); n != 2 {
+		t.Errorf("Expected the context nonce on 2 scripts, got %d in %q", n, body)
+	}
+	if !strings.Contains(body, `nonce="override"`) {
+		t.Errorf("Expected the nonce option to override the context nonce, got: %q", body)
+	}
+
+	if _, err := NewExecuteScriptEvent("1", WithExecuteScriptAttributeKVs("", "1")); err == nil || errors.Is(err, ErrInvalidWireValue) {
+		t.Errorf("Expected an attribute name error, got: %v", err)
+	}
+}
+
+func TestExecuteScriptNonceAttribute(t *testing.T) {
+	r := httptest.NewRequest("GET", "/test", nil)
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, r.WithContext(ContextWithScriptNonce(r.Context(), "ctx")))
+
+	if err := sse.ExecuteScript("1", WithExecuteScriptAttributeKVs("nonce", "attr")); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := sse.PatchHead([]HeadChange{EnsureScript("/a.js")}, WithExecuteScriptAttributes(`nonce="attr"`)); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	body := w.Body.String()
+	if strings.Contains(body, "ctx") || strings.Count(body, `<script nonce="attr"`) != 2 {
+		t.Errorf("Expected the nonce attribute once per script, got: %q", body)
+	}
+	if !strings.Contains(body, `{"nonce":"attr"}`) {
+		t.Errorf("Expected ensured scripts to carry the nonce attribute, got: %q", body)
+	}
+}
+
+func TestCallJS(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+
+	if err := sse.CallJS("myApp.refresh", "</script>", 2, []string{"a"}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	want := `myApp.refresh("\u003c/script\u003e", 2, ["a"])</script>`
+	if !strings.Contains(w.Body.String(), want) {
+		t.Errorf("Expected %q in %q", want, w.Body.String())
+	}
+
+	if err := sse.CallJS("alert(1)//"); err == nil {
+		t.Error("Expected an error for an invalid function path")
+	}
+}
+
+func TestScriptHelpersQuoting(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+
+	// \u2028 is a line terminator in JavaScript strings before ES2019 and %q left \x7f escaped with \x
+	if err := sse.ConsoleLog("a\u2028b\x7f"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := sse.Redirect(`/next?a="1"`); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := sse.Prefetch(`/a"b`, "/c"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	body := w.Body.String()
+	for _, want := range []string{
+		"console.log(\"a\\u2028b\x7f\")",
+		`setTimeout(() => window.location.href = "/next?a=\"1\"")`,
+		`"/a\"b",`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in %q", want, body)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package datastar
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/starfederation/datastar-go/datastar/js"
+)
+
+// HeadChange is one change to the document head made by
+// [ServerSentEventGenerator.PatchHead]. Create it with [SetTitle],
+// [UpsertMeta], [UpsertMetaProperty], [UpsertLink], [EnsureStylesheet]
+// or [EnsureScript].
+type HeadChange struct {
+	title *string
+	// tag, key and value identify the element: the first tag whose key
+	// attribute equals value, or with once, any tag whose key resolves to
+	// the same URL as value.
+	tag   string
+	key   string
+	value string
+	attrs []string
+	// once adds the element only if the page has none
+	once bool
+}
+
+// SetTitle sets the title of the document.
+func SetTitle(title string) HeadChange {
+	return HeadChange{title: &title}
+}
+
+// UpsertMeta sets the content of the `<meta name="...">` element with the name,
+// such as "description" or "theme-color", and adds it if missing.
+func UpsertMeta(name, content string) HeadChange {
+	return HeadChange{tag: "meta", key: "name", value: name, attrs: []string{"content", content}}
+}
+
+// UpsertMetaProperty is the variant of [UpsertMeta] for `<meta property="...">`
+// elements, such as Open Graph's "og:title".
+func UpsertMetaProperty(property, content string) HeadChange {
+	return HeadChange{tag: "meta", key: "property", value: property, attrs: []string{"content", content}}
+}
+
+// UpsertLink sets the href of the `<link rel="...">` element with the rel,
+// such as "canonical" or "icon", and adds it if missing. Further attributes
+// are given as key, value pairs.
+func UpsertLink(rel, href string, attrKVs ...string) HeadChange {
+	return HeadChange{tag: "link", key: "rel", value: rel, attrs: append([]string{"href", href}, attrKVs...)}
+}
+
+// EnsureStylesheet adds a stylesheet link to the head, unless the page
+// already has a stylesheet link to the same URL. URLs are compared once
+// resolved against the base URL of the page, so "/a.css" and
+// "https://host/a.css" are the same stylesheet. Further attributes are
+// given as key, value pairs.
+func EnsureStylesheet(href string, attrKVs ...string) HeadChange {
+	return HeadChange{tag: "link", key: "href", value: href, attrs: append([]string{"rel", "stylesheet"}, attrKVs...), once: true}
+}
+
+// EnsureScript adds a script with the source URL to the head, unless the page
+// already has one, so the script is loaded and run once per page. URLs are
+// compared as with [EnsureStylesheet]. Further attributes, such as `type`,
+// `module`, are given as key, value pairs. The script carries the nonce of
+// the script running the changes.
+func EnsureScript(src string, attrKVs ...string) HeadChange {
+	return HeadChange{tag: "script", key: "src", value: src, attrs: attrKVs, once: true}
+}
+
+// headScript declares the helpers the changes call. upsert finds the first
+// head element of tag whose key attribute equals value, creates it if
+// missing, and sets the attributes. ensure creates the element unless the
+// page has one of tag whose resolved URL property key equals value resolved,
+// and whose rel, if the attributes set one, includes it.
+const headScript = `const head = document.head;
+const upsert = (tag, key, value, attrs) => {
+	let el = [...head.getElementsByTagName(tag)].find((el) => el.getAttribute(key) === value);
+	const created = !el;
+	if (created) {
+		el = document.createElement(tag);
+		el.setAttribute(key, value);
+	}
+	for (const [k, v] of Object.entries(attrs)) el.setAttribute(k, v);
+	if (created) head.appendChild(el);
+};
+const ensure = (tag, key, value, attrs) => {
+	const url = new URL(value, document.baseURI).href;
+	const found = [...document.getElementsByTagName(tag)].some(
+		(el) => el[key] === url && (!attrs.rel || el.relList.contains(attrs.rel)),
+	);
+	if (found) return;
+	const el = document.createElement(tag);
+	el.setAttribute(key, value);
+	for (const [k, v] of Object.entries(attrs)) el.setAttribute(k, v);
+	head.appendChild(el);
+};
+`
+
+// PatchHead is a convenience method for [ServerSentEventGenerator.ExecuteScript].
+// It applies the changes to the document head in a single script, for
+// example to update the title, description and canonical link after a
+// navigation:
+//
+//	sse.PatchHead([]datastar.HeadChange{
+//		datastar.SetTitle(page.Title),
+//		datastar.UpsertMeta("description", page.Summary),
+//		datastar.UpsertLink("canonical", page.URL),
+//		datastar.EnsureStylesheet("/static/editor.css"),
+//	})
+//
+// Elements are matched by attribute value rather than by selector,
+// so values need no CSS escaping. The options configure the script, such
+// as its event ID; scripts added with [EnsureScript] carry its nonce.
+func (sse *ServerSentEventGenerator) PatchHead(changes []HeadChange, opts ...ExecuteScriptOption) error {
+	if len(changes) == 0 {
+		return errors.New("no head changes")
+	}
+	options := &executeScriptOptions{Nonce: ScriptNonceFromContext(sse.ctx)}
+	for _, opt := range opts {
+		opt(options)
+	}
+	nonce := options.Nonce
+	if attr, ok := scriptAttribute(options.Attributes, "nonce"); ok {
+		nonce = attr
+	}
+
+	var sb strings.Builder
+	sb.WriteString("{\n")
+	sb.WriteString(headScript)
+	for _, c := range changes {
+		if c.title != nil {
+			sb.WriteString(js.Assign(js.MustIdent("document.title"), js.String(*c.title)).String())
+			sb.WriteString(";\n")
+			continue
+		}
+
+		if len(c.attrs)%2 != 0 {
+			return fmt.Errorf("head %s %q: attributes require key, value pairs", c.tag, c.value)
+		}
+		attrs := make(map[string]string, len(c.attrs)/2+1)
+		for i := 0; i < len(c.attrs); i += 2 {
+			if !isAttributeName(c.attrs[i]) {
+				return fmt.Errorf("head %s %q: invalid attribute name %q", c.tag, c.value, c.attrs[i])
+			}
+			attrs[c.attrs[i]] = c.attrs[i+1]
+		}
+		if c.tag == "script" && nonce != "" {
+			if _, ok := attrs["nonce"]; !ok {
+				attrs["nonce"] = nonce
+			}
+		}
+		attrsJS, err := js.Value(attrs)
+		if err != nil {
+			return fmt.Errorf("failed to marshal head attributes: %w", err)
+		}
+
+		helper := js.MustIdent("upsert")
+		if c.once {
+			helper = js.MustIdent("ensure")
+		}
+		sb.WriteString(js.Call(helper, js.String(c.tag), js.String(c.key), js.String(c.value), attrsJS).String())
+		sb.WriteString(";\n")
+	}
+	sb.WriteString("}")
+
+	return sse.ExecuteScript(sb.String(), opts...)
+}
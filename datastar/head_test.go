@@ -0,0 +1,97 @@
+package datastar
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPatchHead(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/test", nil)
+	sse := NewSSE(w, r.WithContext(ContextWithScriptNonce(r.Context(), "abc")))
+
+	err := sse.PatchHead([]HeadChange{
+		SetTitle(`Items "new"`),
+		UpsertMeta("description", "</script><b>"),
+		UpsertMetaProperty("og:title", "Items"),
+		UpsertLink("canonical", "https://example.com/items"),
+		EnsureStylesheet("/static/app.css"),
+		EnsureScript("/static/app.js", "type", "module"),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	body := w.Body.String()
+	if n := strings.Count(body, "event: datastar-patch-elements"); n != 1 {
+		t.Errorf("Expected a single event, got: %d", n)
+	}
+	for _, want := range []string{
+		`<script nonce="abc"`,
+		`document.title = "Items \"new\"";`,
+		`upsert("meta", "name", "description", {"content":"\u003c/script\u003e\u003cb\u003e"});`,
+		`upsert("meta", "property", "og:title", {"content":"Items"});`,
+		`upsert("link", "rel", "canonical", {"href":"https://example.com/items"});`,
+		`ensure("link", "href", "/static/app.css", {"rel":"stylesheet"});`,
+		`ensure("script", "src", "/static/app.js", {"nonce":"abc","type":"module"});`,
+		`new URL(value, document.baseURI).href`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %s, got: %q", want, body)
+		}
+	}
+}
+
+func TestPatchHeadScriptOptions(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/test", nil)
+	sse := NewSSE(w, r.WithContext(ContextWithScriptNonce(r.Context(), "abc")))
+
+	err := sse.PatchHead(
+		[]HeadChange{EnsureScript("/static/app.js")},
+		WithExecuteScriptEventID("head-1"),
+		WithExecuteScriptRetryDuration(5*time.Second),
+		WithExecuteScriptNonce("xyz"),
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	body := w.Body.String()
+	for _, want := range []string{
+		"id: head-1\n",
+		"retry: 5000\n",
+		`<script nonce="xyz"`,
+		`ensure("script", "src", "/static/app.js", {"nonce":"xyz"});`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %s, got: %q", want, body)
+		}
+	}
+}
+
+func TestPatchHeadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes []HeadChange
+	}{
+		{"no changes", nil},
+		{"odd attributes", []HeadChange{EnsureScript("/a.js", "type")}},
+		{"invalid attribute", []HeadChange{EnsureStylesheet("/a.css", `media"`, "print")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+			if err := sse.PatchHead(tt.changes); err == nil {
+				t.Error("Expected an error, got: nil")
+			}
+			if strings.Contains(w.Body.String(), "event:") {
+				t.Errorf("Expected no event, got: %q", w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package datastar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Hub fans events out to every [ServerSentEventGenerator] subscribed to a topic.
+// It is safe for concurrent use.
+type Hub struct {
+	mu     sync.RWMutex
+	topics map[string]map[*hubSubscriber]struct{}
+}
+
+type hubSubscriber struct {
+	sse    *ServerSentEventGenerator
+	id     string
+	topics []string
+	stop   func() bool
+}
+
+// NewHub creates an empty [Hub].
+func NewHub() *Hub {
+	return &Hub{
+		topics: map[string]map[*hubSubscriber]struct{}{},
+	}
+}
+
+// subscribeOptions holds the configuration data for [SubscribeOption]s.
+type subscribeOptions struct {
+	ID string
+}
+
+// SubscribeOption configures one [Hub.Subscribe] call.
+type SubscribeOption func(*subscribeOptions)
+
+// WithSubscriberID tags the subscription with an identifier, such as a user ID,
+// that publishers can filter on with [WithPublishTo], [WithPublishExcept]
+// and [WithPublishFilter].
+func WithSubscriberID(id string) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.ID = id
+	}
+}
+
+// Subscribe adds the stream to the given topics. The subscription is removed
+// automatically when the stream context ends, or earlier by calling the
+// returned function.
+func (h *Hub) Subscribe(sse *ServerSentEventGenerator, topics []string, opts ...SubscribeOption) (unsubscribe func()) {
+	options := &subscribeOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	sub := &hubSubscriber{
+		sse:    sse,
+		id:     options.ID,
+		topics: slices.Clone(topics),
+	}
+
+	h.mu.Lock()
+	for _, topic := range sub.topics {
+		subs, ok := h.topics[topic]
+		if !ok {
+			subs = map[*hubSubscriber]struct{}{}
+			h.topics[topic] = subs
+		}
+		subs[sub] = struct{}{}
+	}
+	h.mu.Unlock()
+
+	sub.stop = context.AfterFunc(sse.Context(), func() {
+		h.remove(sub)
+	})
+
+	return func() {
+		sub.stop()
+		h.remove(sub)
+	}
+}
+
+func (h *Hub) remove(sub *hubSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, topic := range sub.topics {
+		subs := h.topics[topic]
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+}
+
+// Subscribers returns the number of streams subscribed to the topic.
+func (h *Hub) Subscribers(topic string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.topics[topic])
+}
+
+// publishOptions holds the configuration data for [PublishOption]s.
+type publishOptions struct {
+	Filter func(subscriberID string) bool
+}
+
+// PublishOption configures one publish call on a [Hub].
+type PublishOption func(*publishOptions)
+
+// WithPublishFilter only delivers the event to subscribers for which
+// filter returns true. It receives the ID set with [WithSubscriberID].
+func WithPublishFilter(filter func(subscriberID string) bool) PublishOption {
+	return func(o *publishOptions) {
+		o.Filter = filter
+	}
+}
+
+// WithPublishTo only delivers the event to subscribers with one of the given IDs.
+func WithPublishTo(ids ...string) PublishOption {
+	return WithPublishFilter(func(subscriberID string) bool {
+		return slices.Contains(ids, subscriberID)
+	})
+}
+
+// WithPublishExcept delivers the event to every subscriber except those with one of the given IDs.
+func WithPublishExcept(ids ...string) PublishOption {
+	return WithPublishFilter(func(subscriberID string) bool {
+		return !slices.Contains(ids, subscriberID)
+	})
+}
+
+// Publish calls send for every stream subscribed to the topic that passes the
+// publish filters. Streams that are gone, because they were closed, their
+// send queue overflowed or writing to the connection failed, are unsubscribed.
+// Errors of open streams, such as a value that fails to marshal, are joined
+// into the returned error and leave the subscriptions in place.
+func (h *Hub) Publish(topic string, send func(sse *ServerSentEventGenerator) error, opts ...PublishOption) error {
+	options := &publishOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	h.mu.RLock()
+	subs := make([]*hubSubscriber, 0, len(h.topics[topic]))
+	for sub := range h.topics[topic] {
+		if options.Filter == nil || options.Filter(sub.id) {
+			subs = append(subs, sub)
+		}
+	}
+	h.mu.RUnlock()
+
+	var errs []error
+	for _, sub := range subs {
+		err := send(sub.sse)
+		if err == nil {
+			continue
+		}
+		if sub.sse.isStreamGone(err) {
+			sub.stop()
+			h.remove(sub)
+			if sub.sse.IsClosed() {
+				continue
+			}
+		}
+		errs = append(errs, fmt.Errorf("subscriber %q: %w", sub.id, err))
+	}
+	return errors.Join(errs...)
+}
+
+// PublishEvent sends a pre-encoded [Event] to every subscriber of the topic,
+// so the event is encoded once regardless of the number of subscribers.
+func (h *Hub) PublishEvent(topic string, evt Event, opts ...PublishOption) error {
+	return h.Publish(topic, func(sse *ServerSentEventGenerator) error {
+		return sse.SendEvent(evt)
+	}, opts...)
+}
+
+// PatchElements is a convenience wrapper for [Hub.PublishEvent] that sends
+// the event of [ServerSentEventGenerator.PatchElements] to every subscriber of the topic.
+func (h *Hub) PatchElements(topic string, elements string, opts ...PatchElementOption) error {
+	evt, err := NewPatchElementsEvent(elements, opts...)
+	if err != nil {
+		return err
+	}
+	return h.PublishEvent(topic, evt)
+}
+
+// PatchSignals is a convenience wrapper for [Hub.PublishEvent] that sends
+// the event of [ServerSentEventGenerator.PatchSignals] to every subscriber of the topic.
+func (h *Hub) PatchSignals(topic string, signalsContents []byte, opts ...PatchSignalsOption) error {
+	evt, err := NewPatchSignalsEvent(signalsContents, opts...)
+	if err != nil {
+		return err
+	}
+	return h.PublishEvent(topic, evt)
+}
+
+// ExecuteScript is a convenience wrapper for [Hub.PublishEvent] that sends
+// the event of [ServerSentEventGenerator.ExecuteScript] to every subscriber of the topic.
+func (h *Hub) ExecuteScript(topic string, scriptContents string, opts ...ExecuteScriptOption) error {
+	evt, err := NewExecuteScriptEvent(scriptContents, opts...)
+	if err != nil {
+		return err
+	}
+	return h.PublishEvent(topic, evt)
+}
@@ -0,0 +1,98 @@
+package datastar
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHubPublish(t *testing.T) {
+	hub := NewHub()
+
+	newSubscriber := func(id string) (*httptest.ResponseRecorder, context.CancelFunc) {
+		ctx, cancel := context.WithCancel(context.Background())
+		w := httptest.NewRecorder()
+		sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil).WithContext(ctx))
+		hub.Subscribe(sse, []string{"room"}, WithSubscriberID(id))
+		return w, cancel
+	}
+
+	alice, cancelAlice := newSubscriber("alice")
+	defer cancelAlice()
+	bob, cancelBob := newSubscriber("bob")
+
+	if err := hub.PatchElements("room", `<div id="a"></div>`); err != nil {
+		t.Fatalf("Expected no error publishing, got: %v", err)
+	}
+	if err := hub.Publish("room", func(sse *ServerSentEventGenerator) error {
+		return sse.PatchElements(`<div id="private"></div>`)
+	}, WithPublishExcept("bob")); err != nil {
+		t.Fatalf("Expected no error publishing, got: %v", err)
+	}
+
+	if !strings.Contains(alice.Body.String(), `id="a"`) || !strings.Contains(bob.Body.String(), `id="a"`) {
+		t.Error("Expected both subscribers to receive the broadcast")
+	}
+	if !strings.Contains(alice.Body.String(), "private") || strings.Contains(bob.Body.String(), "private") {
+		t.Error("Expected only alice to receive the filtered event")
+	}
+
+	cancelBob()
+	// AfterFunc runs in its own goroutine, publishing also prunes closed streams
+	if err := hub.PatchElements("room", `<div id="b"></div>`); err != nil {
+		t.Fatalf("Expected no error publishing to a closed subscriber, got: %v", err)
+	}
+	if got := hub.Subscribers("room"); got != 1 {
+		t.Errorf("Expected 1 subscriber after cancellation, got: %d", got)
+	}
+}
+
+// brokenRecorder fails every write once broken is set, like a reset connection.
+type brokenRecorder struct {
+	*httptest.ResponseRecorder
+	broken bool
+}
+
+func (b *brokenRecorder) Write(p []byte) (int, error) {
+	if b.broken {
+		return 0, errors.New("connection reset")
+	}
+	return b.ResponseRecorder.Write(p)
+}
+
+func TestHubPublishErrors(t *testing.T) {
+	hub := NewHub()
+	for _, id := range []string{"a", "b", "c"} {
+		sse := NewSSE(httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil))
+		hub.Subscribe(sse, []string{"room"}, WithSubscriberID(id))
+	}
+
+	// errors unrelated to the connection keep the subscriptions
+	if err := hub.Publish("room", func(sse *ServerSentEventGenerator) error {
+		return sse.MarshalAndPatchSignals(func() {})
+	}); err == nil {
+		t.Error("Expected a marshal error, got: nil")
+	}
+	err := hub.Publish("room", func(sse *ServerSentEventGenerator) error {
+		return sse.PatchElements(`<div></div>`, WithSelector("#a\nevent: x"))
+	})
+	if !errors.Is(err, ErrInvalidWireValue) {
+		t.Errorf("Expected ErrInvalidWireValue, got: %v", err)
+	}
+	if got := hub.Subscribers("room"); got != 3 {
+		t.Errorf("Expected 3 subscribers after failed sends, got: %d", got)
+	}
+
+	// a failed write means the stream is gone
+	w := &brokenRecorder{ResponseRecorder: httptest.NewRecorder()}
+	hub.Subscribe(NewSSE(w, httptest.NewRequest("GET", "/test", nil)), []string{"room"}, WithSubscriberID("d"))
+	w.broken = true
+	if err := hub.PatchElements("room", `<div id="b"></div>`); err == nil {
+		t.Error("Expected a write error, got: nil")
+	}
+	if got := hub.Subscribers("room"); got != 3 {
+		t.Errorf("Expected the broken subscriber removed, got: %d subscribers", got)
+	}
+}
@@ -0,0 +1,189 @@
+// Package scripts builds the scripts sent by the convenience methods of the
+// datastar package, and parses them back for the datastartest package.
+// Both sides share the code around the values, which are read back with a
+// JSON decoder, so a change to a script cannot silently break its parser.
+package scripts
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/starfederation/datastar-go/datastar/js"
+)
+
+// The code around the URL of a [Redirect] or [Navigate] script.
+const (
+	navigationStart = "setTimeout(() => "
+	locationHref    = "window.location.href = "
+	locationAssign  = "window.location.assign("
+	locationReplace = "window.location.replace("
+	navigationDelay = ", "
+	navigationEnd   = ")"
+	callEnd         = ")"
+)
+
+// Redirect returns the script loading url by assigning location.href.
+func Redirect(url string) string {
+	return navigationStart + locationHref + js.String(url).String() + navigationEnd
+}
+
+// Navigate returns the script loading url with location.assign, or
+// location.replace if replace is set, after delayMS milliseconds if positive.
+func Navigate(url string, replace bool, delayMS int64) string {
+	call := locationAssign
+	if replace {
+		call = locationReplace
+	}
+	script := navigationStart + call + js.String(url).String() + callEnd
+	if delayMS > 0 {
+		script += navigationDelay + strconv.FormatInt(delayMS, 10)
+	}
+	return script + navigationEnd
+}
+
+// ParseNavigation returns the URL loaded by a script built by [Redirect]
+// or [Navigate].
+func ParseNavigation(script string) (string, bool) {
+	sc := scanner{s: script, ok: true}
+	sc.literal(navigationStart)
+
+	var url string
+	switch {
+	case sc.skip(locationHref):
+		sc.value(&url)
+	case sc.skip(locationAssign), sc.skip(locationReplace):
+		sc.value(&url)
+		sc.literal(callEnd)
+	default:
+		return "", false
+	}
+	if sc.skip(navigationDelay) {
+		var delayMS int64
+		sc.value(&delayMS)
+	}
+	sc.literal(navigationEnd)
+	return url, sc.done()
+}
+
+// The code around the values of a [CustomEvent] script.
+const (
+	eventStart      = "\n{\n\tconst elements = "
+	eventName       = "\n\n\tconst event = new CustomEvent("
+	eventBubbles    = ", {\n\t\tbubbles: "
+	eventCancelable = ",\n\t\tcancelable: "
+	eventComposed   = ",\n\t\tcomposed: "
+	eventDetail     = ",\n\t\tdetail: "
+	eventEnd        = ",\n\t});\n\n\telements.forEach((element) => {\n\t\telement.dispatchEvent(event);\n\t});\n}\n\t"
+
+	documentElements = "[document]"
+	selectorElements = "document.querySelectorAll("
+)
+
+// Event is a custom event dispatched by a [CustomEvent] script.
+type Event struct {
+	Name string
+	// Selector is the CSS selector of the dispatching elements,
+	// or empty for the document.
+	Selector   string
+	Bubbles    bool
+	Cancelable bool
+	Composed   bool
+	// Detail is the JSON-encoded event detail.
+	Detail []byte
+}
+
+// CustomEvent returns the script dispatching e. It fails if the
+// detail is not valid JSON.
+func CustomEvent(e Event) (string, error) {
+	elements := documentElements
+	if e.Selector != "" {
+		elements = selectorElements + js.String(e.Selector).String() + callEnd
+	}
+	detail, err := js.JSON(e.Detail)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(eventStart)
+	sb.WriteString(elements)
+	sb.WriteString(eventName)
+	sb.WriteString(js.String(e.Name).String())
+	sb.WriteString(eventBubbles)
+	sb.WriteString(strconv.FormatBool(e.Bubbles))
+	sb.WriteString(eventCancelable)
+	sb.WriteString(strconv.FormatBool(e.Cancelable))
+	sb.WriteString(eventComposed)
+	sb.WriteString(strconv.FormatBool(e.Composed))
+	sb.WriteString(eventDetail)
+	sb.WriteString(detail.String())
+	sb.WriteString(eventEnd)
+	return sb.String(), nil
+}
+
+// ParseCustomEvent returns the event dispatched by a script built by [CustomEvent].
+func ParseCustomEvent(script string) (Event, bool) {
+	var e Event
+	sc := scanner{s: script, ok: true}
+	sc.literal(eventStart)
+	if !sc.skip(documentElements) {
+		sc.literal(selectorElements)
+		sc.value(&e.Selector)
+		sc.literal(callEnd)
+	}
+	sc.literal(eventName)
+	sc.value(&e.Name)
+	sc.literal(eventBubbles)
+	sc.value(&e.Bubbles)
+	sc.literal(eventCancelable)
+	sc.value(&e.Cancelable)
+	sc.literal(eventComposed)
+	sc.value(&e.Composed)
+	sc.literal(eventDetail)
+	var detail json.RawMessage
+	sc.value(&detail)
+	e.Detail = detail
+	sc.literal(eventEnd)
+	return e, sc.done()
+}
+
+// scanner reads a script from the start. Once a read fails, ok is false
+// and later reads do nothing.
+type scanner struct {
+	s  string
+	ok bool
+}
+
+// literal reads code that must come next.
+func (sc *scanner) literal(code string) {
+	sc.ok = sc.skip(code)
+}
+
+// skip reads code if it comes next and reports whether it did.
+func (sc *scanner) skip(code string) bool {
+	if !sc.ok || !strings.HasPrefix(sc.s, code) {
+		return false
+	}
+	sc.s = sc.s[len(code):]
+	return true
+}
+
+// value reads a JSON value, which is how [js] embeds values, into v.
+func (sc *scanner) value(v any) {
+	if !sc.ok {
+		return
+	}
+	dec := json.NewDecoder(strings.NewReader(sc.s))
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil || json.Unmarshal(raw, v) != nil {
+		sc.ok = false
+		return
+	}
+	sc.s = sc.s[dec.InputOffset():]
+}
+
+// done reports whether the whole script was read.
+func (sc *scanner) done() bool {
+	return sc.ok && sc.s == ""
+}
@@ -0,0 +1,59 @@
+package scripts
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNavigation(t *testing.T) {
+	urls := []string{"/next", `/q?a="1"&b=')'`, "), 5)", "/ünï\u2028code"}
+	for _, url := range urls {
+		for _, script := range []string{
+			Redirect(url),
+			Navigate(url, false, 0),
+			Navigate(url, true, 1500),
+		} {
+			got, ok := ParseNavigation(script)
+			if !ok || got != url {
+				t.Errorf("ParseNavigation(%q): Expected %q, got: %q %v", script, url, got, ok)
+			}
+		}
+	}
+
+	for _, script := range []string{
+		`setTimeout(() => window.location.reload())`,
+		`setTimeout(() => window.open("/next", "_blank", "noopener"))`,
+		Redirect("/next") + ";alert(1)",
+		`console.log("/next")`,
+	} {
+		if got, ok := ParseNavigation(script); ok {
+			t.Errorf("ParseNavigation(%q): Expected no URL, got: %q", script, got)
+		}
+	}
+}
+
+func TestParseCustomEvent(t *testing.T) {
+	events := []Event{
+		{Name: "saved", Bubbles: true, Cancelable: true, Composed: true, Detail: []byte(`{"id":1}`)},
+		{Name: `quote"d`, Selector: `[data-x="1"], main > section`, Detail: []byte(`null`)},
+		// the code following the detail inside a string, and an indented detail
+		{Name: "tricky", Bubbles: true, Detail: []byte("{\n  \"s\": \",\\n\\t});\",\n  \"n\": [1, 2]\n}")},
+	}
+	for _, want := range events {
+		script, err := CustomEvent(want)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		got, ok := ParseCustomEvent(script)
+		if !ok || !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseCustomEvent(%q): Expected %+v, got: %+v %v", script, want, got, ok)
+		}
+	}
+
+	if _, err := CustomEvent(Event{Name: "bad", Detail: []byte(`{`)}); err == nil {
+		t.Error("Expected an error for an invalid detail")
+	}
+	if e, ok := ParseCustomEvent(Redirect("/next")); ok {
+		t.Errorf("Expected no event, got: %+v", e)
+	}
+}
@@ -0,0 +1,153 @@
+// Package js builds small JavaScript expressions for scripts executed by
+// Datastar, such as with the ExecuteJS method of the datastar package.
+//
+// Values are embedded as JSON, which is valid JavaScript and escapes the
+// characters that could end a string or a script element, and identifiers
+// are validated, so expressions built from untrusted input cannot inject code.
+// Only [Raw] embeds code verbatim.
+package js
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Expr is a JavaScript expression. The zero value is the empty expression.
+type Expr struct {
+	code string
+}
+
+// String returns the JavaScript source of the expression.
+func (e Expr) String() string {
+	return e.code
+}
+
+// Raw returns trusted code as an expression, without any validation.
+// Never pass it untrusted input.
+func Raw(code string) Expr {
+	return Expr{code: code}
+}
+
+// ErrInvalidIdentifier is returned for identifiers that are not a dotted
+// path of JavaScript identifier names, such as `myApp.refresh`.
+var ErrInvalidIdentifier = errors.New("invalid javascript identifier")
+
+// Ident returns a reference to a dotted path of identifiers, such as
+// `window.location.href`. It fails with [ErrInvalidIdentifier] if any part of
+// the path is not an identifier name.
+func Ident(path string) (Expr, error) {
+	if path == "" {
+		return Expr{}, fmt.Errorf("%w: empty", ErrInvalidIdentifier)
+	}
+	for part := range strings.SplitSeq(path, ".") {
+		if !isIdentifierName(part) {
+			return Expr{}, fmt.Errorf("%w: %q", ErrInvalidIdentifier, path)
+		}
+	}
+	return Expr{code: path}, nil
+}
+
+// MustIdent is like [Ident] but panics on invalid identifiers.
+// It is meant for constant paths.
+func MustIdent(path string) Expr {
+	e, err := Ident(path)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// isIdentifierName reports whether s is an ECMAScript IdentifierName
+// without escape sequences.
+func isIdentifierName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '$' || r == '_' || unicode.IsLetter(r) || unicode.Is(unicode.Nl, r):
+		case i > 0 && (unicode.IsDigit(r) || unicode.In(r, unicode.Mn, unicode.Mc, unicode.Pc) || r == '\u200c' || r == '\u200d'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Value returns v marshaled with [encoding/json] as an expression.
+func Value(v any) (Expr, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return Expr{}, fmt.Errorf("failed to marshal javascript value: %w", err)
+	}
+	return Expr{code: string(b)}, nil
+}
+
+// JSON returns an already marshaled JSON value as an expression.
+// It fails if b is not valid JSON.
+func JSON(b []byte) (Expr, error) {
+	if !json.Valid(b) {
+		return Expr{}, errors.New("invalid JSON value")
+	}
+	return Expr{code: string(b)}, nil
+}
+
+// String returns s as a string literal.
+func String(s string) Expr {
+	// marshaling a string cannot fail
+	b, _ := json.Marshal(s)
+	return Expr{code: string(b)}
+}
+
+// Call returns the call of fn with the arguments.
+func Call(fn Expr, args ...Expr) Expr {
+	var sb strings.Builder
+	sb.WriteString(fn.code)
+	sb.WriteByte('(')
+	for i, arg := range args {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(arg.code)
+	}
+	sb.WriteByte(')')
+	return Expr{code: sb.String()}
+}
+
+// CallFunc returns the call of the function at path, validated by [Ident],
+// with the arguments marshaled by [Value].
+func CallFunc(path string, args ...any) (Expr, error) {
+	fn, err := Ident(path)
+	if err != nil {
+		return Expr{}, err
+	}
+	exprs := make([]Expr, len(args))
+	for i, arg := range args {
+		if exprs[i], err = Value(arg); err != nil {
+			return Expr{}, fmt.Errorf("argument %d: %w", i, err)
+		}
+	}
+	return Call(fn, exprs...), nil
+}
+
+// Assign returns the assignment of value to target.
+func Assign(target, value Expr) Expr {
+	return Expr{code: target.code + " = " + value.code}
+}
+
+// Arrow returns an arrow function without parameters evaluating body.
+func Arrow(body Expr) Expr {
+	return Expr{code: "() => " + body.code}
+}
+
+// Statements joins expressions into a sequence of statements.
+func Statements(exprs ...Expr) Expr {
+	codes := make([]string, len(exprs))
+	for i, e := range exprs {
+		codes[i] = e.code
+	}
+	return Expr{code: strings.Join(codes, "; ")}
+}
@@ -0,0 +1,67 @@
+package js
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIdent(t *testing.T) {
+	for _, path := range []string{"a", "myApp.refresh", "$", "_x.y1", "window.location.href", "ünï.cödé"} {
+		if _, err := Ident(path); err != nil {
+			t.Errorf("Ident(%q): Expected no error, got: %v", path, err)
+		}
+	}
+	for _, path := range []string{"", "a.", ".a", "1a", "a b", "a()", "a;alert(1)", "a[0]", "a-b", "a.b\n"} {
+		if _, err := Ident(path); !errors.Is(err, ErrInvalidIdentifier) {
+			t.Errorf("Ident(%q): Expected ErrInvalidIdentifier, got: %v", path, err)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"plain", `"plain"`},
+		{`"quoted" \ back`, `"\"quoted\" \\ back"`},
+		{"</script>", `"\u003c/script\u003e"`},
+		{"line\u2028sep\u2029", `"line\u2028sep\u2029"`},
+		{"\x00\x7f\U0001F600", "\"\\u0000\x7f\U0001F600\""},
+		{"\xff", "\"\uFFFD\""},
+	}
+	for _, tt := range tests {
+		if got := String(tt.in).String(); got != tt.want {
+			t.Errorf("String(%q): Expected %s, got: %s", tt.in, tt.want, got)
+		}
+	}
+}
+
+func TestCallFunc(t *testing.T) {
+	call, err := CallFunc("myApp.refresh", 1, "two", map[string]bool{"three": true}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if want := `myApp.refresh(1, "two", {"three":true}, null)`; call.String() != want {
+		t.Errorf("Expected %s, got: %s", want, call)
+	}
+
+	if _, err := CallFunc("alert(1);x"); !errors.Is(err, ErrInvalidIdentifier) {
+		t.Errorf("Expected ErrInvalidIdentifier, got: %v", err)
+	}
+	if _, err := CallFunc("f", make(chan int)); err == nil {
+		t.Error("Expected a marshal error")
+	}
+}
+
+func TestCompose(t *testing.T) {
+	expr := Statements(
+		Call(MustIdent("setTimeout"), Arrow(Assign(MustIdent("window.location.href"), String("/x")))),
+		Call(MustIdent("console.log"), Raw("1 + 1")),
+	)
+	want := `setTimeout(() => window.location.href = "/x"); console.log(1 + 1)`
+	if expr.String() != want {
+		t.Errorf("Expected %s, got: %s", want, expr)
+	}
+
+	if _, err := JSON([]byte(`{"a":`)); err == nil {
+		t.Error("Expected an error for invalid JSON")
+	}
+}
@@ -0,0 +1,42 @@
+package datastar
+
+import "encoding/json"
+
+// JSONCodec encodes and decodes the JSON used for signals and
+// custom event details. Implementations must be safe for concurrent use.
+type JSONCodec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// StdJSONCodec is the [JSONCodec] backed by [encoding/json].
+type StdJSONCodec struct{}
+
+// Marshal calls [json.Marshal].
+func (StdJSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal calls [json.Unmarshal].
+func (StdJSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// DefaultJSONCodec is used by generators without [WithJSONCodec]
+// and by [ReadSignals]. Replace it during program initialization only.
+var DefaultJSONCodec JSONCodec = StdJSONCodec{}
+
+// WithJSONCodec overrides the [DefaultJSONCodec] for one stream.
+func WithJSONCodec(codec JSONCodec) SSEOption {
+	return func(sse *ServerSentEventGenerator) {
+		sse.jsonCodec = codec
+	}
+}
+
+// codec returns the [JSONCodec] of the stream.
+func (sse *ServerSentEventGenerator) codec() JSONCodec {
+	if sse.jsonCodec != nil {
+		return sse.jsonCodec
+	}
+	return DefaultJSONCodec
+}
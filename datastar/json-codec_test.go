@@ -0,0 +1,154 @@
+package datastar
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// upperCodec marks the JSON it produces so tests can tell it was used.
+type upperCodec struct{ StdJSONCodec }
+
+func (c upperCodec) Marshal(v any) ([]byte, error) {
+	b, err := c.StdJSONCodec.Marshal(v)
+	return bytes.ToUpper(b), err
+}
+
+func (c upperCodec) Unmarshal(data []byte, v any) error {
+	return c.StdJSONCodec.Unmarshal(bytes.ToLower(data), v)
+}
+
+func TestWithJSONCodec(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil), WithJSONCodec(upperCodec{}))
+
+	if err := sse.MarshalAndPatchSignals(map[string]string{"a": "b"}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := sse.DispatchCustomEvent("ping", map[string]string{"x": "y"}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `data: signals {"A":"B"}`) {
+		t.Errorf("Expected signals marshaled by the codec, got: %q", body)
+	}
+	if !strings.Contains(body, `detail: {"X":"Y"}`) {
+		t.Errorf("Expected detail marshaled by the codec, got: %q", body)
+	}
+}
+
+func TestMarshalAndPatchSignalsError(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+
+	if err := sse.MarshalAndPatchSignals(map[string]any{"ch": make(chan int)}); err == nil {
+		t.Error("Expected a marshal error")
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected nothing sent, got: %q", w.Body.String())
+	}
+}
+
+func TestReadSignalsWithJSONCodec(t *testing.T) {
+	type signals struct {
+		Name string `json:"name"`
+	}
+	r := httptest.NewRequest("POST", "/test", strings.NewReader(`{"NAME":"BOB"}`))
+	got, err := ReadSignalsOpts[signals](r, WithSignalsJSONCodec(upperCodec{}))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if got.Name != "bob" {
+		t.Errorf("Expected name bob, got: %q", got.Name)
+	}
+
+	r = httptest.NewRequest("POST", "/test", strings.NewReader(`{`))
+	if _, err := ReadSignalsOpts[signals](r, WithSignalsJSONCodec(upperCodec{})); !errors.Is(err, ErrSignalsInvalid) {
+		t.Errorf("Expected ErrSignalsInvalid, got: %v", err)
+	}
+}
+
+func TestReadSignalsWithStdJSONCodecPointer(t *testing.T) {
+	type signals struct {
+		Name string `json:"name"`
+	}
+	for _, body := range []string{`{"name":"bob","unknown":1}`, `{"name":"bob"} {}`} {
+		r := httptest.NewRequest("POST", "/test", strings.NewReader(body))
+		_, err := ReadSignalsOpts[signals](r, WithSignalsJSONCodec(&StdJSONCodec{}), WithDisallowUnknownSignals())
+		if !errors.Is(err, ErrSignalsInvalid) {
+			t.Errorf("Expected ErrSignalsInvalid for %s, got: %v", body, err)
+		}
+	}
+}
+
+type benchSignals struct {
+	ID       int64             `json:"id"`
+	Name     string            `json:"name"`
+	Email    string            `json:"email"`
+	Active   bool              `json:"active"`
+	Score    float64           `json:"score"`
+	Tags     []string          `json:"tags"`
+	Settings map[string]string `json:"settings"`
+}
+
+var benchSignalsValue = benchSignals{
+	ID:       42,
+	Name:     "Ada Lovelace",
+	Email:    "ada@example.com",
+	Active:   true,
+	Score:    99.5,
+	Tags:     []string{"admin", "beta", "ops"},
+	Settings: map[string]string{"theme": "dark", "lang": "en"},
+}
+
+func BenchmarkStdJSONCodec(b *testing.B) {
+	codec := StdJSONCodec{}
+	data, err := codec.Marshal(benchSignalsValue)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("Marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := codec.Marshal(benchSignalsValue); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Unmarshal", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+		for b.Loop() {
+			var v benchSignals
+			if err := codec.Unmarshal(data, &v); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("MarshalAndPatchSignals", func(b *testing.B) {
+		sse := NewSSE(discardResponseWriter{}, httptest.NewRequest("GET", "/test", nil))
+		b.ReportAllocs()
+		for b.Loop() {
+			if err := sse.MarshalAndPatchSignals(benchSignalsValue); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("ReadSignals", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+		for b.Loop() {
+			r := httptest.NewRequest("POST", "/test", bytes.NewReader(data))
+			var v benchSignals
+			if err := ReadSignals(r, &v); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
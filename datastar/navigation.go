@@ -0,0 +1,125 @@
+package datastar
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/starfederation/datastar-go/datastar/internal/scripts"
+	"github.com/starfederation/datastar-go/datastar/js"
+)
+
+// navigateOptions holds the configuration data modified by [NavigateOption]s.
+type navigateOptions struct {
+	Replace       bool
+	NewTab        bool
+	Delay         time.Duration
+	ScriptOptions []ExecuteScriptOption
+}
+
+// NavigateOption configures one [ServerSentEventGenerator.Navigate] call.
+type NavigateOption func(*navigateOptions)
+
+// WithNavigateReplace navigates with [location.replace], so the current page
+// is not kept in the session history and the back button skips it.
+//
+// [location.replace]: https://developer.mozilla.org/en-US/docs/Web/API/Location/replace
+func WithNavigateReplace() NavigateOption {
+	return func(o *navigateOptions) {
+		o.Replace = true
+	}
+}
+
+// WithNavigateDelay navigates after the delay, for example to let the user
+// read a message first.
+func WithNavigateDelay(delay time.Duration) NavigateOption {
+	return func(o *navigateOptions) {
+		o.Delay = delay
+	}
+}
+
+// WithNavigateNewTab opens the URL in a new tab with [window.open] instead,
+// without giving the new page access to the current one. Browsers may block
+// it as a popup, since it does not run in response to a user gesture.
+//
+// [window.open]: https://developer.mozilla.org/en-US/docs/Web/API/Window/open
+func WithNavigateNewTab() NavigateOption {
+	return func(o *navigateOptions) {
+		o.NewTab = true
+	}
+}
+
+// WithNavigateScriptOptions configures the script that navigates,
+// such as its event ID or nonce.
+func WithNavigateScriptOptions(opts ...ExecuteScriptOption) NavigateOption {
+	return func(o *navigateOptions) {
+		o.ScriptOptions = append(o.ScriptOptions, opts...)
+	}
+}
+
+// Navigate is a convenience method for [see.ExecuteScript].
+// It makes the browser load the URL with [location.assign], or as configured
+// by the options. The navigation is deferred with setTimeout, so the client
+// finishes processing the event first.
+//
+// [location.assign]: https://developer.mozilla.org/en-US/docs/Web/API/Location/assign
+func (sse *ServerSentEventGenerator) Navigate(url string, opts ...NavigateOption) error {
+	options := &navigateOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if !options.NewTab {
+		return sse.ExecuteScript(scripts.Navigate(url, options.Replace, options.Delay.Milliseconds()), options.ScriptOptions...)
+	}
+	nav := js.Call(js.MustIdent("window.open"), js.String(url), js.String("_blank"), js.String("noopener"))
+	args := []js.Expr{js.Arrow(nav)}
+	if ms := options.Delay.Milliseconds(); ms > 0 {
+		args = append(args, js.Raw(fmt.Sprint(ms)))
+	}
+	return sse.ExecuteJS(js.Call(js.MustIdent("setTimeout"), args...), options.ScriptOptions...)
+}
+
+// PushURL is a convenience method for [see.ExecuteScript].
+// It adds the URL to the browser's session history with [history.pushState],
+// without loading it. The state is marshaled to JSON with the [JSONCodec] of
+// the stream and is available in popstate events; nil pushes a null state.
+//
+// [history.pushState]: https://developer.mozilla.org/en-US/docs/Web/API/History/pushState
+func (sse *ServerSentEventGenerator) PushURL(url string, state any, opts ...ExecuteScriptOption) error {
+	return sse.historyState("window.history.pushState", url, state, opts)
+}
+
+// ReplaceURLState is a convenience method for [see.ExecuteScript].
+// It is the variant of [ServerSentEventGenerator.ReplaceURL] that also sets
+// the state of the current history entry, like [ServerSentEventGenerator.PushURL].
+func (sse *ServerSentEventGenerator) ReplaceURLState(url string, state any, opts ...ExecuteScriptOption) error {
+	return sse.historyState("window.history.replaceState", url, state, opts)
+}
+
+func (sse *ServerSentEventGenerator) historyState(fn, url string, state any, opts []ExecuteScriptOption) error {
+	b, err := sse.codec().Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history state: %w", err)
+	}
+	stateJS, err := js.JSON(b)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history state: %w", err)
+	}
+	return sse.ExecuteJS(js.Call(js.MustIdent(fn), stateJS, js.String(""), js.String(url)), opts...)
+}
+
+// Reload is a convenience method for [see.ExecuteScript].
+// It reloads the current page with [location.reload].
+//
+// [location.reload]: https://developer.mozilla.org/en-US/docs/Web/API/Location/reload
+func (sse *ServerSentEventGenerator) Reload(opts ...ExecuteScriptOption) error {
+	return sse.ExecuteJS(js.Call(js.MustIdent("setTimeout"), js.Arrow(js.Call(js.MustIdent("window.location.reload")))), opts...)
+}
+
+// Back is a convenience method for [see.ExecuteScript].
+// It goes back one page in the session history with [history.back].
+//
+// [history.back]: https://developer.mozilla.org/en-US/docs/Web/API/History/back
+func (sse *ServerSentEventGenerator) Back(opts ...ExecuteScriptOption) error {
+	return sse.ExecuteJS(js.Call(js.MustIdent("window.history.back")), opts...)
+}
@@ -0,0 +1,71 @@
+package datastar
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNavigation(t *testing.T) {
+	tests := []struct {
+		name string
+		send func(sse *ServerSentEventGenerator) error
+		want string
+	}{
+		{"assign", func(sse *ServerSentEventGenerator) error {
+			return sse.Navigate(`/next?q="x"`)
+		}, `setTimeout(() => window.location.assign("/next?q=\"x\""))`},
+		{"replace with delay", func(sse *ServerSentEventGenerator) error {
+			return sse.Navigate("/login", WithNavigateReplace(), WithNavigateDelay(1500*time.Millisecond))
+		}, `setTimeout(() => window.location.replace("/login"), 1500)`},
+		{"new tab", func(sse *ServerSentEventGenerator) error {
+			return sse.Navigate("https://example.com", WithNavigateNewTab())
+		}, `setTimeout(() => window.open("https://example.com", "_blank", "noopener"))`},
+		{"push state", func(sse *ServerSentEventGenerator) error {
+			return sse.PushURL("/items/2", map[string]int{"page": 2})
+		}, `window.history.pushState({"page":2}, "", "/items/2")`},
+		{"push nil state", func(sse *ServerSentEventGenerator) error {
+			return sse.PushURL("/items", nil)
+		}, `window.history.pushState(null, "", "/items")`},
+		{"replace state", func(sse *ServerSentEventGenerator) error {
+			return sse.ReplaceURLState("/items?sort=asc", "s")
+		}, `window.history.replaceState("s", "", "/items?sort=asc")`},
+		{"reload", func(sse *ServerSentEventGenerator) error {
+			return sse.Reload()
+		}, `setTimeout(() => window.location.reload())`},
+		{"back", func(sse *ServerSentEventGenerator) error {
+			return sse.Back()
+		}, `window.history.back()`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+			if err := tt.send(sse); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if !strings.Contains(w.Body.String(), ">"+tt.want+"</script>") {
+				t.Errorf("Expected script %s, got: %q", tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestNavigateScriptOptions(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+
+	if err := sse.Navigate("/x", WithNavigateScriptOptions(WithExecuteScriptNonce("n"), WithExecuteScriptEventID("7"))); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "id: 7\n") || !strings.Contains(body, `nonce="n"`) {
+		t.Errorf("Expected the script options applied, got: %q", body)
+	}
+
+	if err := sse.PushURL("/x", make(chan int)); err == nil {
+		t.Error("Expected an error for a state that cannot be marshaled")
+	}
+}
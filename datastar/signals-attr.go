@@ -0,0 +1,74 @@
+package datastar
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+)
+
+// SignalsAttrName is the Datastar attribute initializing signals on page load.
+const SignalsAttrName = "data-signals"
+
+// SignalsAttr marshals signals with the [DefaultJSONCodec] and [SignalTagName]
+// tags, exactly like [ServerSentEventGenerator.MarshalAndPatchSignals], and
+// returns them as an escaped [SignalsAttrName] attribute for [html/template]:
+//
+//	<body {{ .SignalsAttr }}>
+//
+// Fields tagged ifmissing are returned in a second `data-signals__ifmissing`
+// attribute.
+func SignalsAttr(signals any) (template.HTMLAttr, error) {
+	attrs, err := signalsAttrs(signals)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for i, attr := range attrs {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(attr[0])
+		sb.WriteString(`="`)
+		sb.WriteString(html.EscapeString(attr[1]))
+		sb.WriteByte('"')
+	}
+	return template.HTMLAttr(sb.String()), nil
+}
+
+// SignalsAttributes is the variant of [SignalsAttr] for [Templ], which escapes
+// attribute values itself. Spread the result into an element after converting
+// it to templ.Attributes:
+//
+//	<body { templ.Attributes(attrs)... }>
+//
+// [Templ]: https://templ.guide/
+func SignalsAttributes(signals any) (map[string]any, error) {
+	attrs, err := signalsAttrs(signals)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]any, len(attrs))
+	for _, attr := range attrs {
+		m[attr[0]] = attr[1]
+	}
+	return m, nil
+}
+
+// signalsAttrs returns the unescaped name and value of each signals attribute.
+func signalsAttrs(signals any) ([][2]string, error) {
+	patch, ifMissing, err := marshalSignals(DefaultJSONCodec, signals)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal signals: %w", err)
+	}
+
+	var attrs [][2]string
+	if patch != nil {
+		attrs = append(attrs, [2]string{SignalsAttrName, string(patch)})
+	}
+	if ifMissing != nil {
+		attrs = append(attrs, [2]string{SignalsAttrName + "__ifmissing", string(ifMissing)})
+	}
+	return attrs, nil
+}
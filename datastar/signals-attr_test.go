@@ -0,0 +1,47 @@
+package datastar
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+)
+
+func TestSignalsAttr(t *testing.T) {
+	type view struct {
+		Name   string `json:"name"`
+		Draft  string `datastar:"draft,ifmissing"`
+		Secret string `datastar:",private"`
+	}
+	v := view{Name: `x' onmouseover="alert(1)" </script>&`, Draft: "d", Secret: "s"}
+
+	attr, err := SignalsAttr(v)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	want := `data-signals="{&#34;name&#34;:&#34;x&#39; onmouseover=\&#34;alert(1)\&#34; \u003c/script\u003e\u0026&#34;}" ` +
+		`data-signals__ifmissing="{&#34;draft&#34;:&#34;d&#34;}"`
+	if string(attr) != want {
+		t.Errorf("Expected %s, got: %s", want, attr)
+	}
+
+	tmpl := template.Must(template.New("page").Parse(`<body {{ . }}></body>`))
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, attr); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if sb.String() != "<body "+want+"></body>" {
+		t.Errorf("Expected the attribute rendered verbatim, got: %s", sb.String())
+	}
+
+	attrs, err := SignalsAttributes(v)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if attrs["data-signals"] != `{"name":"x' onmouseover=\"alert(1)\" \u003c/script\u003e\u0026"}` || attrs["data-signals__ifmissing"] != `{"draft":"d"}` {
+		t.Errorf("Expected unescaped JSON attributes, got: %v", attrs)
+	}
+
+	if _, err := SignalsAttr(make(chan int)); err == nil {
+		t.Error("Expected a marshal error")
+	}
+}
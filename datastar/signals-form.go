@@ -0,0 +1,321 @@
+package datastar
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxFormMemory is the number of bytes of a multipart request
+// held in memory while reading signals; larger file parts are stored
+// in temporary files. See [http.Request.ParseMultipartForm].
+var DefaultMaxFormMemory int64 = 32 << 20
+
+var (
+	fileHeaderType      = reflect.TypeFor[*multipart.FileHeader]()
+	fileHeaderSliceType = reflect.TypeFor[[]*multipart.FileHeader]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// isFormRequest reports whether the request body holds form-encoded signals,
+// as sent by Datastar actions with `contentType: 'form'`.
+func isFormRequest(r *http.Request) bool {
+	if isQuerySignalsMethod(r.Method) {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data"
+}
+
+// limitedBody fails reads past its limit with [ErrSignalsTooLarge].
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		b.exceeded = true
+		return 0, ErrSignalsTooLarge
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// parseFormSignals parses a form-encoded or multipart request body into
+// its fields, uploaded files and the number of bytes read.
+func parseFormSignals(r *http.Request, options *readSignalsOptions) (*requestSignals, error) {
+	limit := options.MaxSize
+	if limit <= 0 {
+		limit = math.MaxInt64
+	}
+	limited := &limitedBody{ReadCloser: r.Body, remaining: limit}
+	r.Body = limited
+
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		err = r.ParseMultipartForm(DefaultMaxFormMemory)
+	} else {
+		err = r.ParseForm()
+	}
+	if limited.exceeded {
+		return nil, fmt.Errorf("%w: body exceeds %d bytes", ErrSignalsTooLarge, options.MaxSize)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to parse form: %w", ErrSignalsInvalid, err)
+	}
+
+	rs := &requestSignals{isForm: true, form: r.PostForm, size: limit - limited.remaining}
+	if r.MultipartForm != nil {
+		rs.files = r.MultipartForm.File
+	}
+	return rs, nil
+}
+
+// decodeForm sets the form values and files on the target, which must be
+// a pointer to a struct or to a map[string]any. Fields are matched by their
+// signal names, nested structs by dotted names such as `user.name` and the
+// fields of embedded structs without a prefix. Uploaded files are set on
+// fields of type *[multipart.FileHeader] or []*[multipart.FileHeader].
+func decodeForm(values url.Values, files map[string][]*multipart.FileHeader, target any, disallowUnknown bool) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer, got %T", target)
+	}
+	v = v.Elem()
+
+	switch {
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String && v.Type().Elem().Kind() == reflect.Interface:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		for key, vals := range values {
+			var value any = vals
+			if len(vals) == 1 {
+				value = vals[0]
+			}
+			v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), reflect.ValueOf(value))
+		}
+		for key, fhs := range files {
+			v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), reflect.ValueOf(fhs))
+		}
+		return nil
+	case v.Kind() == reflect.Struct:
+		known := map[string]struct{}{}
+		if err := decodeFormStruct(values, files, v, "", known); err != nil {
+			return err
+		}
+		if disallowUnknown {
+			for key := range values {
+				if _, ok := known[key]; !ok {
+					return fmt.Errorf("unknown form field %q", key)
+				}
+			}
+			for key := range files {
+				if _, ok := known[key]; !ok {
+					return fmt.Errorf("unknown form file %q", key)
+				}
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("cannot decode form into %s", v.Type())
+	}
+}
+
+func decodeFormStruct(values url.Values, files map[string][]*multipart.FileHeader, v reflect.Value, prefix string, known map[string]struct{}) error {
+	t := v.Type()
+	for _, f := range formFields(t) {
+		if f.private || f.readOnly {
+			continue
+		}
+		key := prefix + f.name
+		ft := t.FieldByIndex(f.index).Type
+
+		var present bool
+		switch {
+		case ft == fileHeaderType || ft == fileHeaderSliceType:
+			known[key] = struct{}{}
+			present = len(files[key]) > 0
+		case isFormStruct(ft):
+			present = hasFormPrefix(values, files, key+".")
+		default:
+			known[key] = struct{}{}
+			_, present = values[key]
+		}
+		if !present && (!isFormStruct(ft) || ft.Kind() == reflect.Pointer) {
+			// nested structs are still walked to declare their fields
+			continue
+		}
+		fv, ok := fieldByIndex(v, f.index, present)
+		if !ok {
+			if present {
+				return fmt.Errorf("form field %q: cannot set embedded pointer to unexported struct", key)
+			}
+			fv = reflect.New(ft).Elem()
+		}
+
+		switch {
+		case ft == fileHeaderType:
+			fv.Set(reflect.ValueOf(files[key][0]))
+		case ft == fileHeaderSliceType:
+			fv.Set(reflect.ValueOf(files[key]))
+		case isFormStruct(ft):
+			if ft.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					fv.Set(reflect.New(ft.Elem()))
+				}
+				fv = fv.Elem()
+			}
+			if err := decodeFormStruct(values, files, fv, key+".", known); err != nil {
+				return err
+			}
+		default:
+			if err := setFormValues(fv, values[key]); err != nil {
+				return fmt.Errorf("form field %q: %w", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// formFields returns the fields of a struct type set from a form. They are
+// named like signals, and the fields of embedded structs are promoted as
+// [encoding/json] does, so they are read without a prefix.
+func formFields(t reflect.Type) []signalField {
+	if s := signalStructOf(t); s != nil {
+		return s.fields
+	}
+	// structs unmarshaling themselves from JSON are still set field by field
+	var fields []signalField
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if tag, ok := parseSignalTag(field); ok {
+			fields = append(fields, signalField{signalTag: tag, index: []int{i}})
+		}
+	}
+	return fields
+}
+
+// isFormStruct reports whether values of type t are decoded field by field.
+func isFormStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && !reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+func hasFormPrefix(values url.Values, files map[string][]*multipart.FileHeader, prefix string) bool {
+	for key := range values {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	for key := range files {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// setFormValues sets every value of a form field on v. Slices receive all
+// values, other kinds the last one.
+func setFormValues(v reflect.Value, vals []string) error {
+	if len(vals) == 0 {
+		return nil
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 && !reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		slice := reflect.MakeSlice(v.Type(), len(vals), len(vals))
+		for i, s := range vals {
+			if err := setFormValue(slice.Index(i), s); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+	return setFormValue(v, vals[len(vals)-1])
+}
+
+func setFormValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setFormValue(v.Elem(), s)
+	}
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(s))
+		}
+	}
+
+	// empty inputs leave numbers and booleans at their zero value
+	if s == "" && v.Kind() != reflect.String && v.Kind() != reflect.Interface {
+		v.SetZero()
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		// checkboxes without a value attribute submit "on"
+		if s == "on" {
+			v.SetBool(true)
+			return nil
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return fmt.Errorf("cannot set %s", v.Type())
+		}
+		v.Set(reflect.ValueOf(s))
+	default:
+		return errors.New("unsupported field type " + v.Type().String())
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package datastar
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/valyala/bytebufferpool"
+)
+
+// signalsContextKey is the context key of the signals cached by [SignalsMiddleware].
+type signalsContextKey struct{}
+
+// SignalsMiddleware reads the Datastar signals of every request once,
+// following the same rules as [ReadSignals], and caches them in the request
+// context. Later handlers, auth checks and loggers can then decode them any
+// number of times with [SignalsFromContext], [ReadSignals] or [ReadSignalsOpts],
+// before or after [NewSSE]. A JSON body is restored after reading, so handlers
+// can still read it directly; a form body is parsed into [http.Request.PostForm].
+// Install it only on routes that receive signals, since it reads every body.
+//
+// [WithMaxSignalsSize] bounds the body read by the middleware. The options
+// also apply to every decode by [SignalsFromContext], while [ReadSignalsOpts]
+// and [ReadSignalsInto] apply their own options to the cached signals,
+// including a smaller [WithMaxSignalsSize].
+// Requests whose signals exceed the size limit are rejected with
+// [http.StatusRequestEntityTooLarge], other read errors with [http.StatusBadRequest].
+func SignalsMiddleware(opts ...ReadSignalsOption) func(http.Handler) http.Handler {
+	options := &readSignalsOptions{
+		MaxSize: DefaultMaxSignalsSize,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rs, err := cacheSignals(r, options)
+			if err != nil {
+				status := http.StatusBadRequest
+				if errors.Is(err, ErrSignalsTooLarge) {
+					status = http.StatusRequestEntityTooLarge
+				}
+				http.Error(w, err.Error(), status)
+				return
+			}
+			ctx := context.WithValue(r.Context(), signalsContextKey{}, rs)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// cacheSignals reads the request signals into memory owned by the cache.
+func cacheSignals(r *http.Request, options *readSignalsOptions) (*requestSignals, error) {
+	buf := bytebufferpool.Get()
+	defer bytebufferpool.Put(buf)
+
+	rs, err := readRequestSignals(r, buf, options)
+	if err != nil {
+		return nil, err
+	}
+	if rs.json != nil {
+		rs.json = bytes.Clone(rs.json)
+		if !isQuerySignalsMethod(r.Method) {
+			r.Body = io.NopCloser(bytes.NewReader(rs.json))
+		}
+	}
+	rs.options = options
+	return rs, nil
+}
+
+// SignalsFromContext decodes the signals cached by [SignalsMiddleware] into
+// the signals target, which should be a pointer to a struct, with the options
+// given to the middleware.
+// It returns [ErrSignalsMissing] if the request carried no signals, and an
+// error if the middleware did not run.
+func SignalsFromContext(ctx context.Context, signals any) error {
+	rs, ok := ctx.Value(signalsContextKey{}).(*requestSignals)
+	if !ok {
+		return fmt.Errorf("no signals in context, is SignalsMiddleware installed?")
+	}
+	if rs.empty() {
+		return ErrSignalsMissing
+	}
+	return rs.decode(signals, rs.options)
+}
+
+// RawSignalsFromContext returns the JSON-encoded signals cached by
+// [SignalsMiddleware], or nil for form requests and requests without signals.
+// The returned slice must not be modified.
+func RawSignalsFromContext(ctx context.Context) []byte {
+	rs, ok := ctx.Value(signalsContextKey{}).(*requestSignals)
+	if !ok {
+		return nil
+	}
+	return rs.json
+}
@@ -0,0 +1,129 @@
+package datastar
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSignalsMiddleware(t *testing.T) {
+	type target struct {
+		Name string `json:"name"`
+	}
+
+	var calls int
+	h := SignalsMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var fromCtx, again target
+		if err := SignalsFromContext(r.Context(), &fromCtx); err != nil {
+			t.Fatalf("Expected no error from context, got: %v", err)
+		}
+		if err := SignalsFromContext(r.Context(), &again); err != nil {
+			t.Fatalf("Expected no error reading twice, got: %v", err)
+		}
+
+		NewSSE(w, r)
+
+		// the body is gone, but ReadSignals uses the cached payload
+		var viaRead target
+		if err := ReadSignals(r, &viaRead); err != nil {
+			t.Fatalf("Expected no error from ReadSignals, got: %v", err)
+		}
+		if fromCtx.Name != "bob" || again.Name != "bob" || viaRead.Name != "bob" {
+			t.Errorf("Unexpected signals: %+v %+v %+v", fromCtx, again, viaRead)
+		}
+		if string(RawSignalsFromContext(r.Context())) != `{"name":"bob"}` {
+			t.Errorf("Unexpected raw signals: %q", RawSignalsFromContext(r.Context()))
+		}
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/test", strings.NewReader(`{"name":"bob"}`)))
+
+	query := url.Values{DatastarKey: {`{"name":"bob"}`}}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test?"+query.Encode(), nil))
+
+	if calls != 2 {
+		t.Errorf("Expected the handler to run twice, got: %d", calls)
+	}
+}
+
+func TestSignalsMiddlewareErrors(t *testing.T) {
+	h := SignalsMiddleware(WithMaxSignalsSize(4))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var target map[string]any
+		if err := SignalsFromContext(r.Context(), &target); !errors.Is(err, ErrSignalsMissing) {
+			t.Errorf("Expected ErrSignalsMissing, got: %v", err)
+		}
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/test", strings.NewReader(`{"name":"bob"}`)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got: %d", w.Code)
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil))
+
+	if err := SignalsFromContext(httptest.NewRequest("GET", "/", nil).Context(), &struct{}{}); err == nil {
+		t.Error("Expected an error without the middleware")
+	}
+}
+
+func TestSignalsMiddlewareForm(t *testing.T) {
+	h := SignalsMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("title") != "hello" {
+			t.Errorf("Expected the parsed form, got: %v", r.PostForm)
+		}
+		var fromCtx, viaRead formSignalsTarget
+		if err := SignalsFromContext(r.Context(), &fromCtx); err != nil {
+			t.Fatalf("Expected no error from context, got: %v", err)
+		}
+		// the body was read once, by the middleware
+		if err := ReadSignals(r, &viaRead); err != nil {
+			t.Fatalf("Expected no error from ReadSignals, got: %v", err)
+		}
+		if fromCtx.Title != "hello" || viaRead.Title != "hello" {
+			t.Errorf("Unexpected signals: %+v %+v", fromCtx, viaRead)
+		}
+	}))
+
+	// a plain form post, without the Datastar-Request header
+	req := httptest.NewRequest("POST", "/test", strings.NewReader("title=hello"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestSignalsMiddlewareOptions(t *testing.T) {
+	type target struct {
+		Name string `json:"name"`
+	}
+
+	h := SignalsMiddleware(WithDisallowUnknownSignals())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v target
+		if err := SignalsFromContext(r.Context(), &v); !errors.Is(err, ErrSignalsInvalid) {
+			t.Errorf("Expected the middleware options to reject unknown signals, got: %v", err)
+		}
+		if err := ReadSignalsInto(r, &v); err != nil || v.Name != "bob" {
+			t.Errorf("Expected the options of the call to apply instead, got: %+v %v", v, err)
+		}
+		if err := ReadSignalsInto(r, &v, WithMaxSignalsSize(8)); !errors.Is(err, ErrSignalsTooLarge) {
+			t.Errorf("Expected the size limit of the call to apply to the cached signals, got: %v", err)
+		}
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/test", strings.NewReader(`{"name":"bob","extra":1}`)))
+}
+
+func TestSignalsMiddlewareRestoresBody(t *testing.T) {
+	h := SignalsMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil || string(body) != `{"name":"bob"}` {
+			t.Errorf("Expected the restored body, got: %q %v", body, err)
+		}
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/test", strings.NewReader(`{"name":"bob"}`)))
+}
@@ -0,0 +1,116 @@
+package datastar
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// SignalsPatch builds a single JSON merge patch from dot-separated signal paths.
+// Create one with [ServerSentEventGenerator.Signals] or [NewSignalsPatch].
+// A SignalsPatch is not safe for concurrent use.
+type SignalsPatch struct {
+	sse  *ServerSentEventGenerator
+	root signalsObject
+	err  error
+}
+
+// signalsObject is an object created by a [SignalsPatch], which it may modify.
+// Maps set by the caller are copied into one before a path descends into them.
+type signalsObject map[string]any
+
+// NewSignalsPatch creates an empty [SignalsPatch] that is not bound to a stream.
+// Use [SignalsPatch.Build] to obtain the JSON document.
+func NewSignalsPatch() *SignalsPatch {
+	return &SignalsPatch{root: signalsObject{}}
+}
+
+// Signals starts a [SignalsPatch] that is sent to this stream by [SignalsPatch.Send]:
+//
+//	sse.Signals().Set("user.profile.name", name).Delete("draft").Send()
+func (sse *ServerSentEventGenerator) Signals() *SignalsPatch {
+	p := NewSignalsPatch()
+	p.sse = sse
+	return p
+}
+
+// Set sets the signal at a dot-separated path, such as "user.profile.name",
+// creating the intermediate objects. The value is marshaled to JSON.
+// A later call for the same path, or a parent path, overrides earlier ones.
+// A map[string]any value set earlier is extended by later calls for paths
+// below it without being modified.
+func (p *SignalsPatch) Set(path string, value any) *SignalsPatch {
+	keys, err := splitSignalPath(path)
+	if err != nil {
+		p.err = errors.Join(p.err, err)
+		return p
+	}
+
+	obj := p.root
+	for _, key := range keys[:len(keys)-1] {
+		var child signalsObject
+		switch v := obj[key].(type) {
+		case signalsObject:
+			child = v
+		case map[string]any:
+			child = make(signalsObject, len(v)+1)
+			maps.Copy(child, v)
+		default:
+			child = signalsObject{}
+		}
+		obj[key] = child
+		obj = child
+	}
+	obj[keys[len(keys)-1]] = value
+	return p
+}
+
+// Delete removes the signal at a dot-separated path on the client,
+// which a merge patch expresses with a null value.
+func (p *SignalsPatch) Delete(path string) *SignalsPatch {
+	return p.Set(path, nil)
+}
+
+// Build returns the JSON merge patch document, marshaled with the
+// [JSONCodec] of the stream, or the [DefaultJSONCodec] if it has none.
+func (p *SignalsPatch) Build() ([]byte, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	codec := DefaultJSONCodec
+	if p.sse != nil {
+		codec = p.sse.codec()
+	}
+	b, err := codec.Marshal(p.root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal signals patch: %w", err)
+	}
+	return b, nil
+}
+
+// Send emits the patch as one [EventTypePatchSignals] event on the stream
+// that created it with [ServerSentEventGenerator.Signals].
+func (p *SignalsPatch) Send(opts ...PatchSignalsOption) error {
+	if p.sse == nil {
+		return errors.New("signals patch is not bound to a stream")
+	}
+	b, err := p.Build()
+	if err != nil {
+		return err
+	}
+	if err := p.sse.PatchSignals(b, opts...); err != nil {
+		return fmt.Errorf("failed to patch signals: %w", err)
+	}
+	return nil
+}
+
+func splitSignalPath(path string) ([]string, error) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys {
+		if key == "" {
+			return nil, fmt.Errorf("invalid signal path %q", path)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,70 @@
+package datastar
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSignalsPatch(t *testing.T) {
+	b, err := NewSignalsPatch().
+		Set("user.profile.name", "bob").
+		Set("user.profile.age", 30).
+		Delete("draft").
+		Set("count", 1).
+		Set("count", 3).
+		Set("flag", true).
+		Set("flag.nested", "x").
+		Build()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	want := `{"count":3,"draft":null,"flag":{"nested":"x"},"user":{"profile":{"age":30,"name":"bob"}}}`
+	if string(b) != want {
+		t.Errorf("Expected %s, got: %s", want, b)
+	}
+
+	if _, err := NewSignalsPatch().Set("a..b", 1).Build(); err == nil {
+		t.Error("Expected an error for an invalid path")
+	}
+}
+
+func TestSignalsPatchCallerMap(t *testing.T) {
+	user := map[string]any{"name": "bob", "profile": map[string]any{"age": 30}}
+	b, err := NewSignalsPatch().
+		Set("user", user).
+		Set("user.x", 1).
+		Set("user.profile.age", 31).
+		Build()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	want := `{"user":{"name":"bob","profile":{"age":31},"x":1}}`
+	if string(b) != want {
+		t.Errorf("Expected %s, got: %s", want, b)
+	}
+	if len(user) != 2 || user["profile"].(map[string]any)["age"] != 30 {
+		t.Errorf("Expected the caller's map untouched, got: %v", user)
+	}
+}
+
+func TestSignalsPatchSend(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+	if err := sse.Signals().Set("a.b", 1).Delete("c").Send(WithOnlyIfMissing(true)); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	want := "event: datastar-patch-signals\n" +
+		"data: onlyIfMissing true\n" +
+		"data: signals {\"a\":{\"b\":1},\"c\":null}\n" +
+		"\n\n"
+	if w.Body.String() != want {
+		t.Errorf("Expected %q, got: %q", want, w.Body.String())
+	}
+
+	if err := NewSignalsPatch().Set("a", 1).Send(); err == nil {
+		t.Error("Expected an error sending an unbound patch")
+	}
+}
@@ -0,0 +1,185 @@
+package datastar
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/valyala/bytebufferpool"
+)
+
+var (
+	// ErrSignalsTooLarge is returned when the signals payload exceeds the maximum size.
+	ErrSignalsTooLarge = errors.New("signals payload too large")
+	// ErrSignalsMissing is returned when signals are required but the request has none.
+	ErrSignalsMissing = errors.New("signals payload missing")
+	// ErrSignalsInvalid is returned when the signals payload cannot be decoded into the target.
+	ErrSignalsInvalid = errors.New("signals payload invalid")
+)
+
+// DefaultMaxSignalsSize is the maximum signals payload size in bytes
+// accepted by [ReadSignalsOpts] unless overridden with [WithMaxSignalsSize].
+var DefaultMaxSignalsSize int64 = 1 << 20
+
+// readSignalsOptions holds the configuration data for [ReadSignalsOption]s.
+type readSignalsOptions struct {
+	MaxSize               int64
+	DisallowUnknownFields bool
+	UseNumber             bool
+	Required              bool
+	RejectReadOnly        bool
+	Codec                 JSONCodec
+	Validate              func(payload []byte) error
+}
+
+// ReadSignalsOption configures one [ReadSignalsOpts] call.
+type ReadSignalsOption func(*readSignalsOptions)
+
+// WithMaxSignalsSize overrides the [DefaultMaxSignalsSize] in bytes.
+// Larger payloads fail with [ErrSignalsTooLarge]. Zero disables the limit.
+func WithMaxSignalsSize(maxSize int64) ReadSignalsOption {
+	return func(o *readSignalsOptions) {
+		o.MaxSize = maxSize
+	}
+}
+
+// WithDisallowUnknownSignals rejects payloads holding signals that do not
+// match a field of the target with [ErrSignalsInvalid].
+// See [json.Decoder.DisallowUnknownFields].
+func WithDisallowUnknownSignals() ReadSignalsOption {
+	return func(o *readSignalsOptions) {
+		o.DisallowUnknownFields = true
+	}
+}
+
+// WithUseNumber decodes numbers into interface values as [json.Number]
+// instead of float64, so large IDs keep their precision.
+// See [json.Decoder.UseNumber].
+func WithUseNumber() ReadSignalsOption {
+	return func(o *readSignalsOptions) {
+		o.UseNumber = true
+	}
+}
+
+// WithRequiredSignals fails with [ErrSignalsMissing] when the request
+// carries no signals, instead of returning the zero value.
+func WithRequiredSignals() ReadSignalsOption {
+	return func(o *readSignalsOptions) {
+		o.Required = true
+	}
+}
+
+// WithRejectReadOnlySignals fails with [ErrSignalsInvalid] when the request
+// changes a field tagged readonly with [SignalTagName], instead of discarding
+// the change. The client sends read-only signals back, so a signal is only
+// rejected when it differs from the value the target already holds: decode
+// into a target loaded with the server-side values using [ReadSignalsInto].
+// Form-encoded payloads are not checked.
+func WithRejectReadOnlySignals() ReadSignalsOption {
+	return func(o *readSignalsOptions) {
+		o.RejectReadOnly = true
+	}
+}
+
+// WithSignalsJSONCodec decodes JSON payloads with the given [JSONCodec]
+// instead of the [DefaultJSONCodec]. [WithDisallowUnknownSignals] and
+// [WithUseNumber] only apply to the [StdJSONCodec].
+func WithSignalsJSONCodec(codec JSONCodec) ReadSignalsOption {
+	return func(o *readSignalsOptions) {
+		o.Codec = codec
+	}
+}
+
+// WithSignalsValidator checks JSON payloads with validate before decoding them.
+// Payloads it rejects fail with [ErrSignalsInvalid] wrapping its error.
+// Form-encoded payloads are not passed to it. The signalschema package
+// builds a validator from the JSON Schema of the target type.
+func WithSignalsValidator(validate func(payload []byte) error) ReadSignalsOption {
+	return func(o *readSignalsOptions) {
+		o.Validate = validate
+	}
+}
+
+// ReadSignalsOpts is the generic, validating variant of [ReadSignals].
+// It decodes the Datastar signals of the request into a value of type T.
+// Failures wrap [ErrSignalsTooLarge], [ErrSignalsMissing] or [ErrSignalsInvalid]
+// so handlers can map them to HTTP status codes with [errors.Is].
+//
+// Requests sent with `contentType: 'form'` are decoded from their
+// application/x-www-form-urlencoded or multipart/form-data body into a struct
+// or a map[string]any. Form fields match the JSON names of struct fields,
+// nested structs use dotted names such as `user.name` while the fields of
+// embedded structs are promoted as [encoding/json] does, and uploaded files are
+// set on fields of type *multipart.FileHeader or []*multipart.FileHeader.
+func ReadSignalsOpts[T any](r *http.Request, opts ...ReadSignalsOption) (T, error) {
+	var signals T
+	err := ReadSignalsInto(r, &signals, opts...)
+	return signals, err
+}
+
+// ReadSignalsInto is the variant of [ReadSignalsOpts] that decodes into an
+// existing signals target, which should be a pointer to a struct. Signals
+// missing from the request, private and read-only fields keep the values
+// the target already holds.
+func ReadSignalsInto(r *http.Request, signals any, opts ...ReadSignalsOption) error {
+	options := &readSignalsOptions{
+		MaxSize: DefaultMaxSignalsSize,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	buf := bytebufferpool.Get()
+	defer bytebufferpool.Put(buf)
+
+	rs, err := readRequestSignals(r, buf, options)
+	if err != nil {
+		return err
+	}
+	if rs.empty() {
+		if options.Required {
+			return ErrSignalsMissing
+		}
+		return nil
+	}
+
+	return rs.decode(signals, options)
+}
+
+// decodeSignals strictly decodes a single JSON value into signals.
+func decodeSignals(dsInput []byte, signals any, options *readSignalsOptions) error {
+	if st, v, ok := taggedSignals(signals); ok {
+		return decodeTaggedSignals(dsInput, st, v, options)
+	}
+
+	codec := options.Codec
+	if codec == nil {
+		codec = DefaultJSONCodec
+	}
+	switch codec.(type) {
+	case StdJSONCodec, *StdJSONCodec:
+	default:
+		if err := codec.Unmarshal(dsInput, signals); err != nil {
+			return fmt.Errorf("%w: %w", ErrSignalsInvalid, err)
+		}
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(dsInput))
+	if options.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if options.UseNumber {
+		dec.UseNumber()
+	}
+	if err := dec.Decode(signals); err != nil {
+		return fmt.Errorf("%w: %w", ErrSignalsInvalid, err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: unexpected data after signals", ErrSignalsInvalid)
+	}
+	return nil
+}
@@ -0,0 +1,345 @@
+package datastar
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// signalsRegistry holds the signal types registered with [RegisterSignals].
+var signalsRegistry = struct {
+	sync.RWMutex
+	types map[string]reflect.Type
+	names map[reflect.Type]string
+}{types: map[string]reflect.Type{}, names: map[reflect.Type]string{}}
+
+// RegisterSignals registers the struct type of signals under a name, so
+// [WriteSignalsTypeScript] and [WriteSignalsSchema] describe it. The
+// `signalsgen` command generates both from the registered types of a package.
+// Register types from an init function or a package-level variable:
+//
+//	var _ = datastar.RegisterSignals("Counter", Counter{})
+//
+// It panics if the name is taken by another type, the type is registered
+// under another name, or signals is not a struct.
+// The result is always true and only allows registering in variable declarations.
+func RegisterSignals(name string, signals any) bool {
+	t := reflect.TypeOf(signals)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || signalStructOf(t) == nil {
+		panic(fmt.Sprintf("datastar: cannot register signals %q of type %T", name, signals))
+	}
+
+	signalsRegistry.Lock()
+	defer signalsRegistry.Unlock()
+	if prev, ok := signalsRegistry.types[name]; ok && prev != t {
+		panic(fmt.Sprintf("datastar: signals %q registered twice, as %s and %s", name, prev, t))
+	}
+	if prev, ok := signalsRegistry.names[t]; ok && prev != name {
+		panic(fmt.Sprintf("datastar: signals %s registered twice, as %q and %q", t, prev, name))
+	}
+	signalsRegistry.types[name] = t
+	signalsRegistry.names[t] = name
+	return true
+}
+
+// registeredSignals returns the registered signal types sorted by name.
+func registeredSignals() ([]string, map[reflect.Type]string) {
+	signalsRegistry.RLock()
+	defer signalsRegistry.RUnlock()
+
+	names := slices.Sorted(maps.Keys(signalsRegistry.types))
+	return names, maps.Clone(signalsRegistry.names)
+}
+
+// signalTypeNamer names the struct types declared by a schema or a set of
+// TypeScript declarations. Registered types use their registered name.
+type signalTypeNamer struct {
+	names map[reflect.Type]string
+	taken map[string]reflect.Type
+}
+
+func newSignalTypeNamer(registered map[reflect.Type]string) *signalTypeNamer {
+	n := &signalTypeNamer{names: map[reflect.Type]string{}, taken: map[string]reflect.Type{}}
+	for t, name := range registered {
+		n.names[t] = name
+		n.taken[name] = t
+	}
+	return n
+}
+
+// name returns the declared name of a named struct type and whether it was
+// named before, or false for anonymous structs, which are declared inline.
+func (n *signalTypeNamer) name(t reflect.Type) (name string, seen bool, ok bool) {
+	if name, ok := n.names[t]; ok {
+		return name, true, true
+	}
+	if t.Name() == "" {
+		return "", false, false
+	}
+	name = t.Name()
+	for i := 2; n.taken[name] != nil; i++ {
+		name = fmt.Sprintf("%s%d", t.Name(), i)
+	}
+	n.names[t] = name
+	n.taken[name] = t
+	return name, false, true
+}
+
+// signalLeafKind classifies types that are not walked field by field.
+func signalLeafKind(t reflect.Type) string {
+	pt := reflect.PointerTo(t)
+	switch {
+	case t.Implements(textMarshalerType) || pt.Implements(textMarshalerType):
+		return "string"
+	case t.Implements(jsonMarshalerType) || pt.Implements(jsonMarshalerType):
+		return ""
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// []byte is encoded as a base64 string
+			return "string"
+		}
+		return "array"
+	case reflect.Array:
+		return "array"
+	case reflect.Map:
+		return "object"
+	}
+	return ""
+}
+
+// jsonSchemaBuilder builds JSON Schema documents for signal types.
+type jsonSchemaBuilder struct {
+	namer *signalTypeNamer
+	defs  map[string]any
+}
+
+func (b *jsonSchemaBuilder) schema(t reflect.Type) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		return map[string]any{"anyOf": []any{b.schema(t.Elem()), map[string]any{"type": "null"}}}
+	}
+
+	if s := signalStructOf(t); s != nil {
+		name, _, named := b.namer.name(t)
+		if named {
+			if _, ok := b.defs[name]; !ok {
+				b.defs[name] = true // placeholder for recursive types
+				b.defs[name] = b.object(s)
+			}
+			return map[string]any{"$ref": "#/$defs/" + name}
+		}
+		return b.object(s)
+	}
+
+	switch kind := signalLeafKind(t); kind {
+	case "":
+		return map[string]any{}
+	case "array":
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case "object":
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	default:
+		return map[string]any{"type": kind}
+	}
+}
+
+func (b *jsonSchemaBuilder) object(s *signalStruct) map[string]any {
+	props := map[string]any{}
+	for _, f := range s.fields {
+		if f.private {
+			continue
+		}
+		prop := b.schema(f.fieldType)
+		if f.quoted {
+			prop = map[string]any{"type": "string"}
+			if f.fieldType.Kind() == reflect.Pointer {
+				prop = map[string]any{"anyOf": []any{prop, map[string]any{"type": "null"}}}
+			}
+		}
+		if f.readOnly {
+			prop = map[string]any{"allOf": []any{prop}, "readOnly": true}
+		}
+		props[f.name] = prop
+	}
+	return map[string]any{"type": "object", "properties": props}
+}
+
+// WriteSignalsSchema writes a JSON Schema (draft 2020-12) declaring every
+// type registered with [RegisterSignals] under `$defs`. Properties follow the
+// [SignalTagName] tags: private fields are left out and read-only fields are
+// marked `readOnly`. Other signals on the page are allowed alongside them.
+func WriteSignalsSchema(w io.Writer) error {
+	names, registered := registeredSignals()
+	b := &jsonSchemaBuilder{namer: newSignalTypeNamer(registered), defs: map[string]any{}}
+
+	signalsRegistry.RLock()
+	for _, name := range names {
+		b.schema(signalsRegistry.types[name])
+	}
+	signalsRegistry.RUnlock()
+
+	doc := map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$defs":   b.defs,
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write signals schema: %w", err)
+	}
+	return nil
+}
+
+// SignalsSchema returns the JSON Schema (draft 2020-12) of the type of
+// signals, which must be a struct or a pointer to one, in the form
+// [WriteSignalsSchema] declares it, with the types it refers to under `$defs`.
+func SignalsSchema(signals any) ([]byte, error) {
+	t := reflect.TypeOf(signals)
+	if t == nil || signalStructOf(t) == nil {
+		return nil, fmt.Errorf("signals must be a struct, got %T", signals)
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	_, registered := registeredSignals()
+	b := &jsonSchemaBuilder{namer: newSignalTypeNamer(registered), defs: map[string]any{}}
+	doc := b.schema(t)
+	doc["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	doc["$defs"] = b.defs
+	return json.Marshal(doc)
+}
+
+// tsBuilder builds TypeScript declarations for signal types.
+type tsBuilder struct {
+	namer   *signalTypeNamer
+	pending []reflect.Type
+}
+
+func (b *tsBuilder) typ(t reflect.Type, indent string) string {
+	if t.Kind() == reflect.Pointer {
+		return b.typ(t.Elem(), indent) + " | null"
+	}
+
+	if s := signalStructOf(t); s != nil {
+		name, seen, named := b.namer.name(t)
+		if named {
+			if !seen {
+				b.pending = append(b.pending, t)
+			}
+			return name
+		}
+		return b.object(s, indent)
+	}
+
+	switch kind := signalLeafKind(t); kind {
+	case "":
+		return "unknown"
+	case "integer":
+		return "number"
+	case "array":
+		elem := b.typ(t.Elem(), indent)
+		if strings.Contains(elem, " ") {
+			return "Array<" + elem + ">"
+		}
+		return elem + "[]"
+	case "object":
+		return "Record<string, " + b.typ(t.Elem(), indent) + ">"
+	default:
+		return kind
+	}
+}
+
+func (b *tsBuilder) object(s *signalStruct, indent string) string {
+	var sb strings.Builder
+	sb.WriteString("{\n")
+	for _, f := range s.fields {
+		if f.private {
+			continue
+		}
+		sb.WriteString(indent + "  ")
+		if f.readOnly {
+			sb.WriteString("readonly ")
+		}
+		sb.WriteString(tsPropertyName(f.name))
+		if f.omitEmpty || f.omitZero || f.fieldType.Kind() == reflect.Pointer {
+			sb.WriteByte('?')
+		}
+		sb.WriteString(": ")
+		switch {
+		case f.quoted && f.fieldType.Kind() == reflect.Pointer:
+			sb.WriteString("string | null")
+		case f.quoted:
+			sb.WriteString("string")
+		default:
+			sb.WriteString(b.typ(f.fieldType, indent+"  "))
+		}
+		sb.WriteString(";\n")
+	}
+	sb.WriteString(indent + "}")
+	return sb.String()
+}
+
+// tsPropertyName quotes property names that are not identifiers.
+func tsPropertyName(name string) string {
+	for i, r := range name {
+		if r == '_' || r == '$' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || (i > 0 && '0' <= r && r <= '9') {
+			continue
+		}
+		b, _ := json.Marshal(name)
+		return string(b)
+	}
+	return name
+}
+
+// WriteSignalsTypeScript writes TypeScript declarations (a .d.ts file) with
+// one exported interface per type registered with [RegisterSignals] and per
+// named struct type they use. Properties follow the [SignalTagName] tags:
+// private fields are left out and read-only fields are declared readonly.
+func WriteSignalsTypeScript(w io.Writer) error {
+	names, registered := registeredSignals()
+	b := &tsBuilder{namer: newSignalTypeNamer(registered)}
+
+	signalsRegistry.RLock()
+	for _, name := range names {
+		b.pending = append(b.pending, signalsRegistry.types[name])
+	}
+	signalsRegistry.RUnlock()
+
+	var sb strings.Builder
+	sb.WriteString("// Code generated by datastar signalsgen. DO NOT EDIT.\n")
+	declared := map[reflect.Type]bool{}
+	for len(b.pending) > 0 {
+		t := b.pending[0]
+		b.pending = b.pending[1:]
+		if declared[t] {
+			continue
+		}
+		declared[t] = true
+		name, _, _ := b.namer.name(t)
+		fmt.Fprintf(&sb, "\nexport interface %s %s\n", name, b.object(signalStructOf(t), ""))
+	}
+
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return fmt.Errorf("failed to write signals declarations: %w", err)
+	}
+	return nil
+}
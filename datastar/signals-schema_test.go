@@ -0,0 +1,128 @@
+package datastar
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type schemaProfile struct {
+	Name string         `json:"name"`
+	Next *schemaProfile `json:"next"`
+}
+
+type schemaCounter struct {
+	Count   int               `json:"count"`
+	UserID  int64             `datastar:"userId,readonly"`
+	Secret  string            `datastar:",private"`
+	Tags    []string          `json:"tags"`
+	Profile schemaProfile     `json:"profile"`
+	Note    string            `datastar:"note,omitempty"`
+	Meta    map[string]string `json:"meta"`
+}
+
+var _ = RegisterSignals("SchemaCounter", schemaCounter{})
+
+func TestWriteSignalsTypeScript(t *testing.T) {
+	var sb strings.Builder
+	if err := WriteSignalsTypeScript(&sb); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	want := `export interface SchemaCounter {
+  count: number;
+  readonly userId: number;
+  tags: string[];
+  profile: schemaProfile;
+  note?: string;
+  meta: Record<string, string>;
+}
+
+export interface schemaProfile {
+  name: string;
+  next?: schemaProfile | null;
+}
+`
+	if !strings.Contains(sb.String(), want) {
+		t.Errorf("Expected declarations:\n%s\ngot:\n%s", want, sb.String())
+	}
+}
+
+func TestWriteSignalsSchema(t *testing.T) {
+	var sb strings.Builder
+	if err := WriteSignalsSchema(&sb); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var doc struct {
+		Defs map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal([]byte(sb.String()), &doc); err != nil {
+		t.Fatalf("Expected a JSON document, got: %v", err)
+	}
+	counter, ok := doc.Defs["SchemaCounter"]
+	if !ok {
+		t.Fatalf("Expected a SchemaCounter definition, got: %s", sb.String())
+	}
+	if _, ok := counter.Properties["Secret"]; ok {
+		t.Error("Expected private fields left out of the schema")
+	}
+	if got := strings.Join(strings.Fields(string(counter.Properties["profile"])), ""); got != `{"$ref":"#/$defs/schemaProfile"}` {
+		t.Errorf("Expected a reference to schemaProfile, got: %s", got)
+	}
+	if _, ok := doc.Defs["schemaProfile"]; !ok {
+		t.Error("Expected a schemaProfile definition")
+	}
+}
+
+func TestSignalsSchema(t *testing.T) {
+	b, err := SignalsSchema(&schemaCounter{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	var doc struct {
+		Ref  string                     `json:"$ref"`
+		Defs map[string]json.RawMessage `json:"$defs"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatalf("Expected a JSON document, got: %v", err)
+	}
+	if doc.Ref != "#/$defs/SchemaCounter" {
+		t.Errorf("Expected a reference to SchemaCounter, got: %s", b)
+	}
+	if _, ok := doc.Defs["schemaProfile"]; !ok {
+		t.Errorf("Expected the nested definitions, got: %s", b)
+	}
+
+	if _, err := SignalsSchema(map[string]any{}); err == nil {
+		t.Error("Expected an error for a non-struct type")
+	}
+}
+
+func TestRegisterSignalsTwice(t *testing.T) {
+	if !RegisterSignals("SchemaCounter", schemaCounter{}) {
+		t.Error("Expected registering the same name and type again to succeed")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic registering a type under a second name")
+		}
+	}()
+	RegisterSignals("OtherCounter", schemaCounter{})
+}
+
+func TestSignalsSchemaQuoted(t *testing.T) {
+	b, err := SignalsSchema(struct {
+		Count int  `json:"count,string"`
+		Limit *int `json:"limit,string"`
+	}{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	want := `"properties":{"count":{"type":"string"},"limit":{"anyOf":[{"type":"string"},{"type":"null"}]}}`
+	if !strings.Contains(string(b), want) {
+		t.Errorf("Expected quoted fields to be strings, got: %s", b)
+	}
+}
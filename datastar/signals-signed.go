@@ -0,0 +1,267 @@
+package datastar
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/valyala/bytebufferpool"
+)
+
+// SignatureSignal is the signal holding the signatures attached by [WithSigned].
+// It is an object with one signature per protected signal, so later signed
+// patches merge into it instead of replacing the signatures of earlier ones.
+// Signals starting with an underscore are not sent back by the client,
+// so the name must not start with one.
+const SignatureSignal = "datastarSignature"
+
+// ErrSignalsSignature is returned by [ReadSignalsVerified] when a signature
+// is missing, uses an unknown key or does not match its signal.
+var ErrSignalsSignature = errors.New("signals signature invalid")
+
+// errSigningUnsupported is returned when [WithSigned] is passed to a method
+// that sends signals without signing them.
+var errSigningUnsupported = errors.New("WithSigned is only supported by MarshalAndPatchSignals")
+
+// SigningKey is an HMAC-SHA256 key used to sign signals.
+// The ID is sent along with the signature so keys can be rotated:
+// sign with the new key and keep verifying with the old ones
+// until every client has received a new signature.
+type SigningKey struct {
+	ID     string
+	Secret []byte
+}
+
+// Bind derives a key with the same ID whose signatures are only valid for
+// the given binding, typically a session ID. Sign and verify with keys bound
+// to the same value, so a signed signal received in one session is rejected
+// in any other.
+func (k SigningKey) Bind(binding string) SigningKey {
+	h := hmac.New(sha256.New, k.Secret)
+	fmt.Fprintf(h, "bind\n%q\n", binding)
+	return SigningKey{ID: k.ID, Secret: h.Sum(nil)}
+}
+
+// signalSignature is the signature of one signal in the [SignatureSignal].
+type signalSignature struct {
+	KeyID string `json:"kid"`
+	MAC   string `json:"mac"`
+}
+
+// WithSigned makes [ServerSentEventGenerator.MarshalAndPatchSignals] attach a
+// signature for each protected signal to the [SignatureSignal]. The protected
+// signals are the struct fields tagged signed with [SignalTagName], or every
+// signal of the patch if none is, except those sent with ifmissing. Signed
+// fields left out of the patch by omitempty are removed from the client, so
+// their absence is signed. Read them back with [ReadSignalsVerified].
+//
+// Protected values are compared after re-encoding them, so they should be
+// strings, booleans, integers or objects and arrays of them.
+//
+// A signature covers the key ID, the signal name and its value only. It does
+// not expire and is valid in any session until its key is retired, so sign
+// with a key bound to the session with [SigningKey.Bind]. Other methods
+// taking a [PatchSignalsOption] fail when given this option.
+func WithSigned(key SigningKey) PatchSignalsOption {
+	return func(o *patchSignalsOptions) {
+		o.SigningKey = &key
+	}
+}
+
+// signSignals adds the signatures of the protected signals of a JSON object
+// of signals to the [SignatureSignal].
+func signSignals(patch []byte, signals any, key *SigningKey) ([]byte, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &members); err != nil {
+		return nil, fmt.Errorf("signed signals must be a JSON object: %w", err)
+	}
+
+	fields := signedFields(signals)
+	if len(fields) == 0 {
+		for name := range members {
+			fields = append(fields, name)
+		}
+	}
+
+	sigs := make(map[string]signalSignature, len(fields))
+	for _, name := range fields {
+		if _, ok := members[name]; !ok {
+			members[name] = json.RawMessage("null")
+		}
+		mac, err := signalMAC(key, name, members[name])
+		if err != nil {
+			return nil, err
+		}
+		sigs[name] = signalSignature{KeyID: key.ID, MAC: mac}
+	}
+	sig, err := json.Marshal(sigs)
+	if err != nil {
+		return nil, err
+	}
+	members[SignatureSignal] = sig
+	return json.Marshal(members)
+}
+
+// signedFields returns the names of the fields of signals tagged signed.
+func signedFields(signals any) []string {
+	s, _, ok := taggedSignals(signals)
+	if !ok {
+		return nil
+	}
+	var fields []string
+	for _, f := range s.fields {
+		if f.signed && !f.private && !f.ifMissing {
+			fields = append(fields, f.name)
+		}
+	}
+	return fields
+}
+
+// signalMAC authenticates the key ID, the name of a signal and its value,
+// re-encoded so formatting differences do not matter. A nil or null value
+// stands for a signal the client does not have.
+func signalMAC(key *SigningKey, name string, raw json.RawMessage) (string, error) {
+	h := hmac.New(sha256.New, key.Secret)
+	fmt.Fprintf(h, "%q\n%q\n", key.ID, name)
+	value, err := canonicalSignal(raw)
+	if err != nil {
+		return "", fmt.Errorf("signal %q: %w", name, err)
+	}
+	h.Write(value)
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// canonicalSignal re-encodes a JSON value with sorted object keys.
+func canonicalSignal(raw []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return []byte("null"), nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// ReadSignalsVerified is the variant of [ReadSignals] for signals signed
+// with [WithSigned]. The protected signals are the fields of signals tagged
+// signed and the given fields; the protected set never comes from the
+// request, and it must not be empty. It fails with [ErrSignalsSignature]
+// unless each protected signal carries a signature made by one of the keys
+// that matches its value. Requests without a JSON payload are rejected.
+func ReadSignalsVerified(r *http.Request, signals any, keys []SigningKey, fields ...string) error {
+	required := append(signedFields(signals), fields...)
+	if len(required) == 0 {
+		return fmt.Errorf("%w: no signals to verify", ErrSignalsSignature)
+	}
+
+	buf := bytebufferpool.Get()
+	defer bytebufferpool.Put(buf)
+
+	options := &readSignalsOptions{MaxSize: DefaultMaxSignalsSize}
+	rs, err := readRequestSignals(r, buf, options)
+	if err != nil {
+		return err
+	}
+	if rs.isForm || rs.json == nil {
+		return fmt.Errorf("%w: request has no JSON signals", ErrSignalsSignature)
+	}
+	if err := verifySignals(rs.json, keys, required); err != nil {
+		return err
+	}
+	return decodeSignals(rs.json, signals, options)
+}
+
+// verifySignals checks the signature of each required signal of a JSON
+// object of signals against the [SignatureSignal]. Since signals are decoded
+// like [encoding/json] does, matching names case-insensitively and keeping
+// the last of duplicate names, payloads with duplicate names or with a name
+// that differs from a required one only in case are rejected.
+func verifySignals(payload []byte, keys []SigningKey, required []string) error {
+	members, err := signalMembers(payload)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSignalsInvalid, err)
+	}
+	for name := range members {
+		for _, req := range required {
+			if name != req && strings.EqualFold(name, req) {
+				return fmt.Errorf("%w: signal %q is not signed", ErrSignalsSignature, name)
+			}
+		}
+	}
+	raw, ok := members[SignatureSignal]
+	if !ok {
+		return fmt.Errorf("%w: missing %s", ErrSignalsSignature, SignatureSignal)
+	}
+	var sigs map[string]signalSignature
+	if err := json.Unmarshal(raw, &sigs); err != nil {
+		return fmt.Errorf("%w: %w", ErrSignalsSignature, err)
+	}
+
+	for _, name := range required {
+		if name == SignatureSignal {
+			return fmt.Errorf("%w: signature covers itself", ErrSignalsSignature)
+		}
+		sig, ok := sigs[name]
+		if !ok {
+			return fmt.Errorf("%w: signal %q is not signed", ErrSignalsSignature, name)
+		}
+		i := slices.IndexFunc(keys, func(k SigningKey) bool { return k.ID == sig.KeyID })
+		if i < 0 {
+			return fmt.Errorf("%w: unknown key %q", ErrSignalsSignature, sig.KeyID)
+		}
+		mac, err := signalMAC(&keys[i], name, members[name])
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrSignalsInvalid, err)
+		}
+		if !hmac.Equal([]byte(mac), []byte(sig.MAC)) {
+			return fmt.Errorf("%w: signal %q was modified", ErrSignalsSignature, name)
+		}
+	}
+	return nil
+}
+
+// signalMembers splits a JSON object of signals into its members,
+// failing on duplicate names.
+func signalMembers(payload []byte) (map[string]json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	if tok, err := dec.Token(); err != nil {
+		return nil, err
+	} else if tok != json.Delim('{') {
+		return nil, errors.New("signals must be a JSON object")
+	}
+
+	members := map[string]json.RawMessage{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		name := tok.(string)
+		if _, ok := members[name]; ok {
+			return nil, fmt.Errorf("duplicate signal %q", name)
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		members[name] = raw
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after signals")
+	}
+	return members, nil
+}
@@ -0,0 +1,242 @@
+package datastar
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type signedView struct {
+	UserID int64  `datastar:"userId,signed"`
+	Role   string `datastar:"role,signed"`
+	Title  string `json:"title"`
+}
+
+// signedPayload sends signals with [WithSigned] and returns them the way
+// a client would echo them back, with reordered keys and extra whitespace.
+func signedPayload(t *testing.T, signals any, key SigningKey) map[string]any {
+	t.Helper()
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+	if err := sse.MarshalAndPatchSignals(signals, WithSigned(key)); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	_, line, ok := strings.Cut(w.Body.String(), "data: signals ")
+	if !ok {
+		t.Fatalf("Expected a signals patch, got: %q", w.Body.String())
+	}
+	line, _, _ = strings.Cut(line, "\n")
+	var payload map[string]any
+	if err := json.Unmarshal([]byte(line), &payload); err != nil {
+		t.Fatalf("Expected JSON signals, got: %v", err)
+	}
+	return payload
+}
+
+// mergeSignals applies a signals patch the way a client does, merging
+// objects and removing null signals.
+func mergeSignals(dst, patch map[string]any) {
+	for k, v := range patch {
+		switch v := v.(type) {
+		case nil:
+			delete(dst, k)
+		case map[string]any:
+			d, ok := dst[k].(map[string]any)
+			if !ok {
+				d = map[string]any{}
+				dst[k] = d
+			}
+			mergeSignals(d, v)
+		default:
+			dst[k] = v
+		}
+	}
+}
+
+func readVerified(payload map[string]any, signals any, keys []SigningKey, fields ...string) error {
+	b, _ := json.MarshalIndent(payload, "", "  ")
+	r := httptest.NewRequest("POST", "/test", strings.NewReader(string(b)))
+	return ReadSignalsVerified(r, signals, keys, fields...)
+}
+
+func TestReadSignalsVerified(t *testing.T) {
+	oldKey := SigningKey{ID: "k1", Secret: []byte("old secret")}
+	newKey := SigningKey{ID: "k2", Secret: []byte("new secret")}
+
+	payload := signedPayload(t, signedView{UserID: 7, Role: "admin", Title: "t"}, newKey)
+	if _, ok := payload[SignatureSignal]; !ok {
+		t.Fatalf("Expected a %s signal, got: %v", SignatureSignal, payload)
+	}
+
+	payload["title"] = "changed by the client"
+	var v signedView
+	if err := readVerified(payload, &v, []SigningKey{oldKey, newKey}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if v.UserID != 7 || v.Role != "admin" || v.Title != "changed by the client" {
+		t.Errorf("Expected signals decoded, got: %+v", v)
+	}
+
+	if err := readVerified(payload, &v, []SigningKey{oldKey}); !errors.Is(err, ErrSignalsSignature) {
+		t.Errorf("Expected ErrSignalsSignature for an unknown key, got: %v", err)
+	}
+
+	payload["userId"] = 8
+	if err := readVerified(payload, &v, []SigningKey{newKey}); !errors.Is(err, ErrSignalsSignature) {
+		t.Errorf("Expected ErrSignalsSignature for a modified signal, got: %v", err)
+	}
+
+	delete(payload, SignatureSignal)
+	if err := readVerified(payload, &v, []SigningKey{newKey}); !errors.Is(err, ErrSignalsSignature) {
+		t.Errorf("Expected ErrSignalsSignature without a signature, got: %v", err)
+	}
+}
+
+func TestReadSignalsVerifiedCoverage(t *testing.T) {
+	key := SigningKey{ID: "k", Secret: []byte("secret")}
+
+	// a signature over unrelated signals must not vouch for signed fields
+	payload := signedPayload(t, map[string]any{"theme": "dark"}, key)
+	payload["userId"] = 1
+	payload["role"] = "admin"
+	var v signedView
+	if err := readVerified(payload, &v, []SigningKey{key}); !errors.Is(err, ErrSignalsSignature) {
+		t.Errorf("Expected ErrSignalsSignature for uncovered fields, got: %v", err)
+	}
+
+	// untagged payloads sign every signal, the reader names the ones it trusts
+	payload = signedPayload(t, map[string]any{"a": 1, "b": []string{"x"}}, key)
+	var m map[string]any
+	if err := readVerified(payload, &m, []SigningKey{key}, "a", "b"); err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+	if err := readVerified(payload, &m, []SigningKey{key}); !errors.Is(err, ErrSignalsSignature) {
+		t.Errorf("Expected ErrSignalsSignature without protected signals, got: %v", err)
+	}
+	if err := readVerified(payload, &m, []SigningKey{key}, "a", "c"); !errors.Is(err, ErrSignalsSignature) {
+		t.Errorf("Expected ErrSignalsSignature for an unsigned signal, got: %v", err)
+	}
+	payload["b"] = []string{"y"}
+	if err := readVerified(payload, &m, []SigningKey{key}, "a", "b"); !errors.Is(err, ErrSignalsSignature) {
+		t.Errorf("Expected ErrSignalsSignature for a modified signal, got: %v", err)
+	}
+}
+
+func TestReadSignalsVerifiedLaterPatch(t *testing.T) {
+	key := SigningKey{ID: "k", Secret: []byte("secret")}
+
+	client := signedPayload(t, map[string]any{"userId": 1}, key)
+	mergeSignals(client, signedPayload(t, map[string]any{"count": 0}, key))
+
+	var m map[string]any
+	if err := readVerified(client, &m, []SigningKey{key}, "userId", "count"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// the second signed patch must not drop the protection of the first
+	client["userId"] = 999
+	if err := readVerified(client, &m, []SigningKey{key}, "userId"); !errors.Is(err, ErrSignalsSignature) {
+		t.Errorf("Expected ErrSignalsSignature for a field of an earlier patch, got: %v", err)
+	}
+}
+
+func TestReadSignalsVerifiedOmitted(t *testing.T) {
+	key := SigningKey{ID: "k", Secret: []byte("secret")}
+	type view struct {
+		Token string `datastar:"token,signed,omitempty"`
+	}
+
+	client := signedPayload(t, view{Token: "t"}, key)
+	mergeSignals(client, signedPayload(t, view{}, key))
+	if _, ok := client["token"]; ok {
+		t.Fatalf("Expected the omitted signed signal removed, got: %v", client)
+	}
+	var v view
+	if err := readVerified(client, &v, []SigningKey{key}); err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+	client["token"] = "t"
+	if err := readVerified(client, &v, []SigningKey{key}); !errors.Is(err, ErrSignalsSignature) {
+		t.Errorf("Expected ErrSignalsSignature for a restored signal, got: %v", err)
+	}
+}
+
+func TestReadSignalsVerifiedBound(t *testing.T) {
+	key := SigningKey{ID: "k", Secret: []byte("secret")}
+
+	payload := signedPayload(t, signedView{UserID: 7, Role: "admin"}, key.Bind("session-a"))
+
+	var v signedView
+	if err := readVerified(payload, &v, []SigningKey{key.Bind("session-a")}); err != nil {
+		t.Fatalf("Expected no error in the same session, got: %v", err)
+	}
+	if err := readVerified(payload, &v, []SigningKey{key.Bind("session-b")}); !errors.Is(err, ErrSignalsSignature) {
+		t.Errorf("Expected ErrSignalsSignature in another session, got: %v", err)
+	}
+	if err := readVerified(payload, &v, []SigningKey{key}); !errors.Is(err, ErrSignalsSignature) {
+		t.Errorf("Expected ErrSignalsSignature with the unbound key, got: %v", err)
+	}
+}
+
+func TestWithSignedUnsupported(t *testing.T) {
+	key := SigningKey{ID: "k", Secret: []byte("secret")}
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+
+	if err := sse.PatchSignals([]byte(`{"a":1}`), WithSigned(key)); err == nil {
+		t.Error("Expected PatchSignals to fail with WithSigned")
+	}
+	if err := sse.PatchSignalsReader(strings.NewReader(`{"a":1}`), WithSigned(key)); err == nil {
+		t.Error("Expected PatchSignalsReader to fail with WithSigned")
+	}
+	if err := sse.Signals().Set("a", 1).Send(WithSigned(key)); err == nil {
+		t.Error("Expected SignalsPatch.Send to fail with WithSigned")
+	}
+	if err := NewSignalsTracker(sse).Patch(signedView{UserID: 7}, WithSigned(key)); err == nil {
+		t.Error("Expected SignalsTracker.Patch to fail with WithSigned")
+	}
+	if _, err := NewPatchSignalsEvent([]byte(`{"a":1}`), WithSigned(key)); err == nil {
+		t.Error("Expected NewPatchSignalsEvent to fail with WithSigned")
+	}
+	if err := NewHub().PatchSignals("topic", []byte(`{"a":1}`), WithSigned(key)); err == nil {
+		t.Error("Expected Hub.PatchSignals to fail with WithSigned")
+	}
+	if strings.Contains(w.Body.String(), "event:") {
+		t.Errorf("Expected nothing sent, got: %q", w.Body.String())
+	}
+}
+
+func TestReadSignalsVerifiedCaseAndDuplicates(t *testing.T) {
+	key := SigningKey{ID: "k", Secret: []byte("secret")}
+	payload := signedPayload(t, signedView{UserID: 7, Role: "admin"}, key)
+
+	payload["USERID"] = 999
+	var v signedView
+	if err := readVerified(payload, &v, []SigningKey{key}); !errors.Is(err, ErrSignalsSignature) {
+		t.Errorf("Expected ErrSignalsSignature for a case variant, got: %v", err)
+	}
+	if v.UserID != 0 {
+		t.Errorf("Expected no signals decoded, got: %+v", v)
+	}
+	delete(payload, "USERID")
+
+	untagged := struct{ UserID int64 }{}
+	payload["UserID"] = 999
+	if err := readVerified(payload, &untagged, []SigningKey{key}, "userId"); !errors.Is(err, ErrSignalsSignature) {
+		t.Errorf("Expected ErrSignalsSignature for a case variant of a given field, got: %v", err)
+	}
+	delete(payload, "UserID")
+
+	b, _ := json.Marshal(payload)
+	body := string(b[:len(b)-1]) + `,"userId":999}`
+	r := httptest.NewRequest("POST", "/test", strings.NewReader(body))
+	if err := ReadSignalsVerified(r, &v, []SigningKey{key}); !errors.Is(err, ErrSignalsInvalid) {
+		t.Errorf("Expected ErrSignalsInvalid for a duplicate signal, got: %v", err)
+	}
+	if v.UserID != 0 {
+		t.Errorf("Expected no signals decoded, got: %+v", v)
+	}
+}
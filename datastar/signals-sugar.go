@@ -1,20 +1,43 @@
 package datastar
 
 import (
-	"encoding/json"
 	"fmt"
 )
 
 // MarshalAndPatchSignals is a convenience method for [see.PatchSignals].
-// It marshals a given signals struct into JSON and
-// emits a [EventTypePatchSignals] event.
+// It marshals a given signals struct into JSON with the [JSONCodec] of the stream
+// and emits a [EventTypePatchSignals] event.
+// Struct fields are sent according to their [SignalTagName] tags; fields
+// tagged ifmissing are sent in a second event with [WithOnlyIfMissing],
+// which does not repeat the event ID of the first.
+// With [WithSigned], the first event carries the [SignatureSignal].
 func (sse *ServerSentEventGenerator) MarshalAndPatchSignals(signals any, opts ...PatchSignalsOption) error {
-	b, err := json.Marshal(signals)
+	patch, ifMissing, err := marshalSignals(sse.codec(), signals)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("failed to marshal signals: %w", err)
 	}
-	if err := sse.PatchSignals(b, opts...); err != nil {
-		return fmt.Errorf("failed to patch signals: %w", err)
+	options := &patchSignalsOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.SigningKey != nil {
+		if patch == nil {
+			patch = []byte("{}")
+		}
+		if patch, err = signSignals(patch, signals, options.SigningKey); err != nil {
+			return fmt.Errorf("failed to sign signals: %w", err)
+		}
+		opts = append(opts[:len(opts):len(opts)], withoutSigning)
+	}
+	if patch != nil {
+		if err := sse.PatchSignals(patch, opts...); err != nil {
+			return fmt.Errorf("failed to patch signals: %w", err)
+		}
+	}
+	if ifMissing != nil {
+		if err := sse.PatchSignals(ifMissing, ifMissingOptions(opts, patch != nil)...); err != nil {
+			return fmt.Errorf("failed to patch signals if missing: %w", err)
+		}
 	}
 
 	return nil
@@ -25,7 +48,7 @@ func (sse *ServerSentEventGenerator) MarshalAndPatchSignals(signals any, opts ..
 func (sse *ServerSentEventGenerator) MarshalAndPatchSignalsIfMissing(signals any, opts ...PatchSignalsOption) error {
 	if err := sse.MarshalAndPatchSignals(
 		signals,
-		append(opts, WithOnlyIfMissing(true))...,
+		append(opts[:len(opts):len(opts)], WithOnlyIfMissing(true))...,
 	); err != nil {
 		return fmt.Errorf("failed to patch signals if missing: %w", err)
 	}
@@ -40,3 +63,19 @@ func (sse *ServerSentEventGenerator) PatchSignalsIfMissingRaw(signalsJSON string
 	}
 	return nil
 }
+
+// ifMissingOptions returns opts for an event sent with [WithOnlyIfMissing].
+// If sent is set, an event already went out with opts, so its event ID
+// is not repeated.
+func ifMissingOptions(opts []PatchSignalsOption, sent bool) []PatchSignalsOption {
+	opts = append(opts[:len(opts):len(opts)], WithOnlyIfMissing(true))
+	if sent {
+		opts = append(opts, WithPatchSignalsEventID(""))
+	}
+	return opts
+}
+
+// withoutSigning clears the key of [WithSigned] once the signals are signed.
+func withoutSigning(o *patchSignalsOptions) {
+	o.SigningKey = nil
+}
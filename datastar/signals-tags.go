@@ -0,0 +1,848 @@
+package datastar
+
+import (
+	"bytes"
+	"cmp"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SignalTagName is the struct tag controlling how a field is sent and read as a signal:
+//
+//	type View struct {
+//		UserID  int64  `datastar:"userId,readonly"`
+//		Draft   string `datastar:",ifmissing"`
+//		Note    string `datastar:",omitempty"`
+//		Session string `datastar:",private"`
+//	}
+//
+// The name overrides the JSON name of the field. Fields are otherwise named,
+// promoted from embedded structs and omitted as [encoding/json] does,
+// including the omitempty, omitzero and string options of json tags.
+// The options are:
+//   - ifmissing: [ServerSentEventGenerator.MarshalAndPatchSignals] sends the
+//     field in a separate patch with [WithOnlyIfMissing].
+//   - omitempty: the field is not sent when it is empty, as [encoding/json]
+//     defines it: false, 0, a nil pointer or interface, or an empty string,
+//     slice, map or array.
+//   - omitzero: the field is not sent when it is zero, as reported by its
+//     IsZero method if it has one.
+//   - private: the field is never sent nor read. A tag of "-" is equivalent.
+//   - readonly: the field is sent, but [ReadSignals] and [ReadSignalsOpts]
+//     never set it from the request, so client changes are discarded. With
+//     [WithRejectReadOnlySignals], changes fail the read with [ErrSignalsInvalid].
+//   - signed: the top-level field is covered by the signature of [WithSigned],
+//     so [ReadSignalsVerified] rejects client changes.
+//
+// Options apply to nested structs as a whole. Tags are also followed in the
+// elements of slices, arrays and maps of structs, where ifmissing has no
+// effect since such values are always patched as a whole. Structs without
+// datastar tags, directly or in their fields, are marshaled and unmarshaled
+// by the [JSONCodec] unchanged.
+const SignalTagName = "datastar"
+
+var (
+	anyType             = reflect.TypeFor[any]()
+	jsonMarshalerType   = reflect.TypeFor[json.Marshaler]()
+	jsonUnmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// signalTag holds the parsed datastar and json tags of a struct field.
+type signalTag struct {
+	name      string
+	named     bool
+	ifMissing bool
+	omitEmpty bool
+	omitZero  bool
+	// quoted is set by the string option of the json tag
+	quoted   bool
+	private  bool
+	readOnly bool
+	signed   bool
+}
+
+// parseSignalTag parses the tags of a struct field. It returns false for
+// fields that are not signals at all.
+func parseSignalTag(field reflect.StructField) (signalTag, bool) {
+	var tag signalTag
+	jsonTag, hasJSON := field.Tag.Lookup("json")
+	dsTag, hasDS := field.Tag.Lookup(SignalTagName)
+	if jsonTag == "-" && !hasDS {
+		return tag, false
+	}
+	if hasJSON && jsonTag != "-" {
+		name, opts, _ := strings.Cut(jsonTag, ",")
+		tag.name, tag.named = name, name != ""
+		tag.omitEmpty = hasTagOption(opts, "omitempty")
+		tag.omitZero = hasTagOption(opts, "omitzero")
+		tag.quoted = hasTagOption(opts, "string")
+	}
+	if dsTag == "-" {
+		tag.private = true
+		return tag, true
+	}
+	if hasDS {
+		name, opts, _ := strings.Cut(dsTag, ",")
+		if name != "" {
+			tag.name, tag.named = name, true
+		}
+		tag.ifMissing = hasTagOption(opts, "ifmissing")
+		tag.omitEmpty = tag.omitEmpty || hasTagOption(opts, "omitempty")
+		tag.omitZero = tag.omitZero || hasTagOption(opts, "omitzero")
+		tag.private = hasTagOption(opts, "private")
+		tag.readOnly = hasTagOption(opts, "readonly")
+		tag.signed = hasTagOption(opts, "signed")
+	}
+	if tag.name == "" {
+		tag.name = field.Name
+	}
+	return tag, true
+}
+
+func hasTagOption(opts, option string) bool {
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+// signalField is a field of a struct sent as signals.
+type signalField struct {
+	signalTag
+	// index is the path to the field through embedded structs
+	index     []int
+	fieldType reflect.Type
+	// nested is set for struct fields that are walked field by field
+	nested *signalStruct
+	// elems is set for slice, array and map fields whose elements are walked
+	elems *signalElems
+}
+
+// signalElems describes the elements of a slice, array or map type that
+// are signal structs, directly or through further slices, arrays and maps.
+type signalElems struct {
+	strct *signalStruct
+	elems *signalElems
+}
+
+// tagged reports whether the element structs have datastar tags.
+func (e *signalElems) tagged() bool {
+	if e.strct != nil {
+		return e.strct.tagged
+	}
+	return e.elems.tagged()
+}
+
+// signalStruct describes how a struct type is sent as signals.
+type signalStruct struct {
+	fields []signalField
+	byName map[string]*signalField
+	// tagged reports whether the struct or a nested one has datastar tags
+	tagged bool
+}
+
+// field returns the field a JSON object member named name decodes into.
+// Like [encoding/json], it prefers an exact match but accepts a
+// case-insensitive one.
+func (s *signalStruct) field(name string) *signalField {
+	if f, ok := s.byName[name]; ok {
+		return f
+	}
+	for i := range s.fields {
+		if strings.EqualFold(s.fields[i].name, name) {
+			return &s.fields[i]
+		}
+	}
+	return nil
+}
+
+var signalStructs sync.Map // map[reflect.Type]*signalStruct
+
+// signalStructOf returns the description of a struct type, or nil if t,
+// after dereferencing pointers, is not a struct walked field by field.
+func signalStructOf(t reflect.Type) *signalStruct {
+	return signalStructIn(t, map[reflect.Type]*signalStruct{})
+}
+
+func signalStructIn(t reflect.Type, building map[reflect.Type]*signalStruct) *signalStruct {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if !isSignalStruct(t) {
+		return nil
+	}
+	if s, ok := signalStructs.Load(t); ok {
+		return s.(*signalStruct)
+	}
+	if s, ok := building[t]; ok {
+		return s
+	}
+
+	s := &signalStruct{byName: map[string]*signalField{}}
+	building[t] = s
+	s.fields = signalFields(s, t, building)
+	for i := range s.fields {
+		f := &s.fields[i]
+		s.byName[f.name] = f
+		s.tagged = s.tagged || (f.nested != nil && f.nested.tagged) || (f.elems != nil && f.elems.tagged())
+	}
+
+	if len(building) == 1 {
+		// only cache complete descriptions, recursive types are resolved from the root
+		actual, _ := signalStructs.LoadOrStore(t, s)
+		return actual.(*signalStruct)
+	}
+	delete(building, t)
+	return s
+}
+
+// signalFields returns the signal fields of the struct type t, ordered by
+// index. Like [encoding/json], the fields of embedded structs without a name
+// are promoted. Of several fields with the same name, the shallowest one wins,
+// then the only one named by a tag; if none of them dominates, all are left out.
+func signalFields(s *signalStruct, t reflect.Type, building map[reflect.Type]*signalStruct) []signalField {
+	type embedded struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	var fields []signalField
+	var count, nextCount map[reflect.Type]int
+	next := []embedded{{typ: t}}
+	visited := map[reflect.Type]bool{}
+	for len(next) > 0 {
+		current := next
+		next = nil
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, e := range current {
+			if visited[e.typ] {
+				continue
+			}
+			visited[e.typ] = true
+
+			for i := range e.typ.NumField() {
+				field := e.typ.Field(i)
+				ft := field.Type
+				if field.Anonymous {
+					if ft.Name() == "" && ft.Kind() == reflect.Pointer {
+						ft = ft.Elem()
+					}
+					if !field.IsExported() && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !field.IsExported() {
+					continue
+				}
+				tag, ok := parseSignalTag(field)
+				if !ok {
+					continue
+				}
+				if _, hasDS := field.Tag.Lookup(SignalTagName); hasDS {
+					s.tagged = true
+				}
+
+				index := append(e.index[:len(e.index):len(e.index)], i)
+				if field.Anonymous && !tag.named && !tag.private && isSignalStruct(ft) {
+					// promote the fields of the embedded struct
+					nextCount[ft]++
+					if nextCount[ft] == 1 {
+						next = append(next, embedded{typ: ft, index: index})
+					}
+					continue
+				}
+
+				if !field.IsExported() {
+					continue
+				}
+				if tag.quoted && !isQuotableSignal(ft) {
+					tag.quoted = false
+				}
+				sf := signalField{
+					signalTag: tag,
+					index:     index,
+					fieldType: ft,
+					nested:    signalStructIn(ft, building),
+				}
+				if sf.nested == nil {
+					sf.elems = signalElemsIn(ft, building)
+				}
+				fields = append(fields, sf)
+				if count[e.typ] > 1 {
+					// embedded twice at the same depth, so the name
+					// conflicts with itself and the field is dropped
+					fields = append(fields, sf)
+				}
+			}
+		}
+	}
+
+	slices.SortFunc(fields, func(a, b signalField) int {
+		if c := strings.Compare(a.name, b.name); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(len(a.index), len(b.index)); c != 0 {
+			return c
+		}
+		if a.named != b.named {
+			if a.named {
+				return -1
+			}
+			return 1
+		}
+		return slices.Compare(a.index, b.index)
+	})
+
+	out := fields[:0]
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].name == fields[i].name {
+			j++
+		}
+		if f, ok := dominantSignalField(fields[i:j]); ok {
+			out = append(out, f)
+		}
+		i = j
+	}
+	slices.SortFunc(out, func(a, b signalField) int {
+		return slices.Compare(a.index, b.index)
+	})
+	return out
+}
+
+// dominantSignalField returns the field that wins among fields sharing a
+// name, sorted by depth and then by whether they are named by a tag.
+func dominantSignalField(fields []signalField) (signalField, bool) {
+	if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) && fields[0].named == fields[1].named {
+		return signalField{}, false
+	}
+	return fields[0], true
+}
+
+// isQuotableSignal reports whether the string option of a json tag applies
+// to fields of type t.
+func isQuotableSignal(t reflect.Type) bool {
+	if t.Name() == "" && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// signalElemsIn returns the description of the elements of a slice, array or
+// map type, or nil if t, after dereferencing pointers, holds no signal structs.
+func signalElemsIn(t reflect.Type, building map[reflect.Type]*signalStruct) *signalElems {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if !isSignalContainer(t) {
+		return nil
+	}
+	if s := signalStructIn(t.Elem(), building); s != nil {
+		return &signalElems{strct: s}
+	}
+	if e := signalElemsIn(t.Elem(), building); e != nil {
+		return &signalElems{elems: e}
+	}
+	return nil
+}
+
+// isSignalContainer reports whether t is a slice, array or map whose
+// elements can be walked rather than handing t to the [JSONCodec].
+func isSignalContainer(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+	case reflect.Map:
+		if !isSignalMapKey(t.Key()) {
+			return false
+		}
+	default:
+		return false
+	}
+	pt := reflect.PointerTo(t)
+	return !pt.Implements(jsonMarshalerType) && !pt.Implements(textMarshalerType) &&
+		!pt.Implements(jsonUnmarshalerType) && !pt.Implements(textUnmarshalerType)
+}
+
+// isSignalMapKey reports whether map keys of type t can be decoded from
+// JSON object names, as [encoding/json] does.
+func isSignalMapKey(t reflect.Type) bool {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+// isSignalStruct reports whether values of type t are walked field by field
+// rather than handed to the [JSONCodec].
+func isSignalStruct(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	pt := reflect.PointerTo(t)
+	return !pt.Implements(jsonMarshalerType) && !pt.Implements(textMarshalerType) &&
+		!pt.Implements(jsonUnmarshalerType) && !pt.Implements(textUnmarshalerType)
+}
+
+// fieldByIndex returns the field at index, or false if an embedded
+// pointer on the way is nil and alloc is false or the pointer is unexported.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !alloc || !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// taggedSignals returns the struct description of signals if it has
+// datastar tags, along with the dereferenced struct value.
+func taggedSignals(signals any) (*signalStruct, reflect.Value, bool) {
+	v := reflect.ValueOf(signals)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil, reflect.Value{}, false
+	}
+	s := signalStructOf(v.Type())
+	if s == nil || !s.tagged {
+		return nil, reflect.Value{}, false
+	}
+	return s, v, true
+}
+
+// marshalSignals marshals signals into a patch and a patch to send with
+// [WithOnlyIfMissing]. Either is nil when it has nothing to send.
+func marshalSignals(codec JSONCodec, signals any) (patch, ifMissing []byte, err error) {
+	s, v, ok := taggedSignals(signals)
+	if !ok {
+		patch, err = codec.Marshal(signals)
+		return patch, nil, err
+	}
+
+	patchValues, ifMissingValues := map[string]any{}, map[string]any{}
+	splitSignals(s, v, patchValues, ifMissingValues, false)
+
+	if len(ifMissingValues) > 0 {
+		if ifMissing, err = codec.Marshal(ifMissingValues); err != nil {
+			return nil, nil, err
+		}
+		if len(patchValues) == 0 {
+			return nil, ifMissing, nil
+		}
+	}
+	if patch, err = codec.Marshal(patchValues); err != nil {
+		return nil, nil, err
+	}
+	return patch, ifMissing, nil
+}
+
+// splitSignals sorts the fields of v into patch and ifMissing.
+// Once parentIfMissing is set, patch and ifMissing are the same map.
+func splitSignals(s *signalStruct, v reflect.Value, patch, ifMissing map[string]any, parentIfMissing bool) {
+	for i := range s.fields {
+		f := &s.fields[i]
+		if f.private {
+			continue
+		}
+		fv, ok := fieldByIndex(v, f.index, false)
+		if !ok || (f.omitEmpty && isEmptySignal(fv)) || (f.omitZero && isZeroSignal(fv)) {
+			continue
+		}
+
+		if parentIfMissing || f.ifMissing {
+			ifMissing[f.name] = fieldSignals(f, fv)
+			continue
+		}
+		if f.nested == nil || (fv.Kind() == reflect.Pointer && fv.IsNil()) {
+			patch[f.name] = fieldSignals(f, fv)
+			continue
+		}
+		for fv.Kind() == reflect.Pointer {
+			fv = fv.Elem()
+		}
+
+		childPatch, childIfMissing := map[string]any{}, map[string]any{}
+		splitSignals(f.nested, fv, childPatch, childIfMissing, false)
+		if len(childPatch) > 0 || len(childIfMissing) == 0 {
+			patch[f.name] = childPatch
+		}
+		if len(childIfMissing) > 0 {
+			ifMissing[f.name] = childIfMissing
+		}
+	}
+}
+
+// fieldSignals returns the value of a field as it is sent, in a single patch.
+func fieldSignals(f *signalField, fv reflect.Value) any {
+	if f.elems != nil {
+		return elemsSignals(fv, f.elems)
+	}
+	if f.quoted {
+		return quotedSignal(fv)
+	}
+	if f.nested == nil {
+		return fv.Interface()
+	}
+	for fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+	fields := map[string]any{}
+	splitSignals(f.nested, fv, fields, fields, true)
+	return fields
+}
+
+// isEmptySignal reports whether the omitempty option of [encoding/json]
+// leaves out v. Unlike the zero value, empty slices and maps are empty
+// and structs never are.
+func isEmptySignal(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+// isZeroSignal reports whether the omitzero option of [encoding/json]
+// leaves out v, using its IsZero method if it has one.
+func isZeroSignal(v reflect.Value) bool {
+	type isZeroer interface{ IsZero() bool }
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return true
+	}
+	if z, ok := v.Interface().(isZeroer); ok {
+		return z.IsZero()
+	}
+	if v.CanAddr() {
+		if z, ok := v.Addr().Interface().(isZeroer); ok {
+			return z.IsZero()
+		}
+	}
+	return v.IsZero()
+}
+
+// quotedSignal returns the value of a field with the string option of a
+// json tag, which is sent as a JSON string holding its JSON encoding.
+func quotedSignal(v reflect.Value) any {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		// left to the codec, which reports the same error
+		return v.Interface()
+	}
+	return string(b)
+}
+
+// elemsSignals returns the value of a slice, array or map holding signal
+// structs, with every element struct reduced to the fields that are sent.
+func elemsSignals(v reflect.Value, e *signalElems) any {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := reflect.MakeMapWithSize(reflect.MapOf(v.Type().Key(), anyType), v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			elem := reflect.New(anyType).Elem()
+			if ev := elemSignals(iter.Value(), e); ev != nil {
+				elem.Set(reflect.ValueOf(ev))
+			}
+			m.SetMapIndex(iter.Key(), elem)
+		}
+		return m.Interface()
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+	}
+	elems := make([]any, v.Len())
+	for i := range elems {
+		elems[i] = elemSignals(v.Index(i), e)
+	}
+	return elems
+}
+
+func elemSignals(v reflect.Value, e *signalElems) any {
+	if e.strct == nil {
+		return elemsSignals(v, e.elems)
+	}
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	fields := map[string]any{}
+	splitSignals(e.strct, v, fields, fields, true)
+	return fields
+}
+
+// decodeTaggedSignals decodes a JSON object into the fields of v one by one,
+// skipping private and read-only fields. Read-only fields whose value differs
+// from the one in v fail when options.RejectReadOnly is set.
+func decodeTaggedSignals(data []byte, s *signalStruct, v reflect.Value, options *readSignalsOptions) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return fmt.Errorf("%w: %w", ErrSignalsInvalid, err)
+	}
+
+	for name, raw := range members {
+		f := s.field(name)
+		if f == nil {
+			if options.DisallowUnknownFields {
+				return fmt.Errorf("%w: unknown signal %q", ErrSignalsInvalid, name)
+			}
+			continue
+		}
+		if f.private {
+			continue
+		}
+		if f.readOnly {
+			if options.RejectReadOnly {
+				if err := checkReadOnlySignal(raw, f, v, options); err != nil {
+					return fmt.Errorf("signal %q: %w", name, err)
+				}
+			}
+			continue
+		}
+
+		fv, ok := fieldByIndex(v, f.index, true)
+		if !ok {
+			return fmt.Errorf("%w: signal %q: cannot set embedded pointer to unexported struct", ErrSignalsInvalid, name)
+		}
+		if f.elems != nil {
+			if err := decodeSignalElems(raw, f.elems, fv, options); err != nil {
+				return fmt.Errorf("signal %q: %w", name, err)
+			}
+			continue
+		}
+		if f.nested == nil {
+			if f.quoted && !bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+				var quoted string
+				if err := json.Unmarshal(raw, &quoted); err != nil {
+					return fmt.Errorf("%w: signal %q: %w", ErrSignalsInvalid, name, err)
+				}
+				raw = json.RawMessage(quoted)
+			}
+			if err := decodeSignals(raw, fv.Addr().Interface(), options); err != nil {
+				return fmt.Errorf("signal %q: %w", name, err)
+			}
+			continue
+		}
+		if fv.Kind() == reflect.Pointer {
+			if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+				fv.SetZero()
+				continue
+			}
+			if fv.IsNil() {
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+		if err := decodeTaggedSignals(raw, f.nested, fv, options); err != nil {
+			return fmt.Errorf("signal %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// checkReadOnlySignal fails with [ErrSignalsInvalid] unless raw encodes the
+// value of the read-only field f of v, as it is sent to the client.
+func checkReadOnlySignal(raw json.RawMessage, f *signalField, v reflect.Value, options *readSignalsOptions) error {
+	var current any
+	if fv, ok := fieldByIndex(v, f.index, false); ok {
+		current = fieldSignals(f, fv)
+	}
+	codec := options.Codec
+	if codec == nil {
+		codec = DefaultJSONCodec
+	}
+	sent, err := codec.Marshal(current)
+	if err != nil {
+		return fmt.Errorf("failed to marshal read-only signal: %w", err)
+	}
+	want, err := canonicalSignal(sent)
+	if err != nil {
+		return fmt.Errorf("failed to marshal read-only signal: %w", err)
+	}
+	got, err := canonicalSignal(raw)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSignalsInvalid, err)
+	}
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("%w: read-only signal was modified", ErrSignalsInvalid)
+	}
+	return nil
+}
+
+// decodeSignalElems decodes a JSON array or object into a slice, array or map
+// holding signal structs. Elements already present at the same index or key
+// are decoded into, so their private and read-only fields are kept.
+func decodeSignalElems(data []byte, e *signalElems, v reflect.Value, options *readSignalsOptions) error {
+	isNull := bytes.Equal(bytes.TrimSpace(data), []byte("null"))
+	for v.Kind() == reflect.Pointer {
+		if isNull {
+			v.SetZero()
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if isNull {
+		if v.Kind() != reflect.Array {
+			v.SetZero()
+		}
+		return nil
+	}
+
+	if v.Kind() == reflect.Map {
+		var members map[string]json.RawMessage
+		if err := json.Unmarshal(data, &members); err != nil {
+			return fmt.Errorf("%w: %w", ErrSignalsInvalid, err)
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(members)))
+		}
+		for name, raw := range members {
+			key, err := signalMapKey(name, v.Type().Key())
+			if err != nil {
+				return fmt.Errorf("%w: key %q: %w", ErrSignalsInvalid, name, err)
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if existing := v.MapIndex(key); existing.IsValid() {
+				elem.Set(existing)
+			}
+			if err := decodeSignalElem(raw, e, elem, options); err != nil {
+				return fmt.Errorf("key %q: %w", name, err)
+			}
+			v.SetMapIndex(key, elem)
+		}
+		return nil
+	}
+
+	var elems []json.RawMessage
+	if err := json.Unmarshal(data, &elems); err != nil {
+		return fmt.Errorf("%w: %w", ErrSignalsInvalid, err)
+	}
+	if v.Kind() == reflect.Slice {
+		slice := reflect.MakeSlice(v.Type(), len(elems), len(elems))
+		reflect.Copy(slice, v)
+		v.Set(slice)
+	}
+	for i := range v.Len() {
+		if i >= len(elems) {
+			v.Index(i).SetZero()
+			continue
+		}
+		if err := decodeSignalElem(elems[i], e, v.Index(i), options); err != nil {
+			return fmt.Errorf("index %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func decodeSignalElem(data []byte, e *signalElems, v reflect.Value, options *readSignalsOptions) error {
+	if e.strct == nil {
+		return decodeSignalElems(data, e.elems, v, options)
+	}
+	isNull := bytes.Equal(bytes.TrimSpace(data), []byte("null"))
+	for v.Kind() == reflect.Pointer {
+		if isNull {
+			v.SetZero()
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return decodeTaggedSignals(data, e.strct, v, options)
+}
+
+// signalMapKey converts a JSON object name to a map key of type t,
+// as [encoding/json] does.
+func signalMapKey(name string, t reflect.Type) (reflect.Value, error) {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		key := reflect.New(t)
+		if err := key.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(name)); err != nil {
+			return reflect.Value{}, err
+		}
+		return key.Elem(), nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(name).Convert(t), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(name, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(n).Convert(t), nil
+	default:
+		n, err := strconv.ParseUint(name, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(n).Convert(t), nil
+	}
+}
@@ -0,0 +1,376 @@
+package datastar
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type taggedProfile struct {
+	Name  string `json:"name"`
+	Theme string `datastar:"theme,ifmissing"`
+}
+
+type taggedBase struct {
+	Version int `json:"version"`
+}
+
+type taggedView struct {
+	taggedBase
+	UserID  int64          `datastar:"userId,readonly"`
+	Title   string         `json:"title"`
+	Draft   string         `json:"draft" datastar:",ifmissing"`
+	Note    string         `datastar:"note,omitempty"`
+	Session string         `datastar:",private"`
+	Secret  string         `datastar:"-"`
+	Profile taggedProfile  `json:"profile"`
+	Prefs   *taggedProfile `datastar:"prefs,ifmissing"`
+	Ignored string         `json:"-"`
+}
+
+func TestMarshalAndPatchSignalsTags(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+
+	v := taggedView{
+		taggedBase: taggedBase{Version: 2},
+		UserID:     7,
+		Title:      "t",
+		Draft:      "d",
+		Session:    "s",
+		Secret:     "x",
+		Profile:    taggedProfile{Name: "bob", Theme: "dark"},
+		Prefs:      &taggedProfile{Name: "p"},
+		Ignored:    "i",
+	}
+	if err := sse.MarshalAndPatchSignals(&v); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	want := "event: datastar-patch-signals\n" +
+		`data: signals {"profile":{"name":"bob"},"title":"t","userId":7,"version":2}` + "\n" +
+		"\n\n" +
+		"event: datastar-patch-signals\n" +
+		"data: onlyIfMissing true\n" +
+		`data: signals {"draft":"d","prefs":{"name":"p","theme":""},"profile":{"theme":"dark"}}` + "\n" +
+		"\n\n"
+	if w.Body.String() != want {
+		t.Errorf("Expected %q, got: %q", want, w.Body.String())
+	}
+}
+
+func TestMarshalAndPatchSignalsIfMissingOptions(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+
+	opts := make([]PatchSignalsOption, 1, 4)
+	opts[0] = WithPatchSignalsEventID("7")
+	if err := sse.MarshalAndPatchSignals(taggedProfile{Name: "bob"}, opts...); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := sse.MarshalAndPatchSignalsIfMissing(taggedProfile{Name: "bob"}, opts...); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if n := strings.Count(w.Body.String(), "id: 7\n"); n != 2 {
+		t.Errorf("Expected the event ID on the first event of each call only, got %d in: %q", n, w.Body.String())
+	}
+	if spare := opts[:2][1]; spare != nil {
+		t.Error("Expected the caller's options not to be written to")
+	}
+}
+
+func TestMarshalAndPatchSignalsUntagged(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+
+	type plain struct {
+		B string `json:"b"`
+		A string `json:"a"`
+	}
+	if err := sse.MarshalAndPatchSignals(plain{B: "1", A: "2"}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !strings.Contains(w.Body.String(), `data: signals {"b":"1","a":"2"}`) {
+		t.Errorf("Expected untagged structs to keep their encoding, got: %q", w.Body.String())
+	}
+}
+
+func TestReadSignalsTags(t *testing.T) {
+	body := `{"version":3,"userId":99,"title":"new","session":"evil","Session":"evil","Secret":"evil","profile":{"name":"alice","theme":"light"},"prefs":{"name":"q"}}`
+
+	v := taggedView{UserID: 7, Session: "s", Secret: "x"}
+	r := httptest.NewRequest("POST", "/test", strings.NewReader(body))
+	if err := ReadSignals(r, &v); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if v.UserID != 7 || v.Session != "s" || v.Secret != "x" {
+		t.Errorf("Expected read-only and private fields untouched, got: %+v", v)
+	}
+	if v.Version != 3 || v.Title != "new" || v.Profile.Name != "alice" || v.Profile.Theme != "light" || v.Prefs == nil || v.Prefs.Name != "q" {
+		t.Errorf("Expected writable fields set, got: %+v", v)
+	}
+
+	r = httptest.NewRequest("POST", "/test", strings.NewReader(`{"title":"x","unknown":1}`))
+	if _, err := ReadSignalsOpts[taggedView](r, WithDisallowUnknownSignals()); !errors.Is(err, ErrSignalsInvalid) {
+		t.Errorf("Expected ErrSignalsInvalid for an unknown signal, got: %v", err)
+	}
+
+	r = httptest.NewRequest("POST", "/test", strings.NewReader(`{"title":1}`))
+	if _, err := ReadSignalsOpts[taggedView](r); !errors.Is(err, ErrSignalsInvalid) {
+		t.Errorf("Expected ErrSignalsInvalid for a mistyped signal, got: %v", err)
+	}
+}
+
+func TestReadSignalsTagsForm(t *testing.T) {
+	form := url.Values{"userId": {"99"}, "title": {"new"}, "profile.theme": {"light"}}
+	r := httptest.NewRequest("POST", "/test", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	v, err := ReadSignalsOpts[taggedView](r)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if v.UserID != 0 || v.Title != "new" || v.Profile.Theme != "light" {
+		t.Errorf("Expected form fields to follow signal tags, got: %+v", v)
+	}
+}
+
+func TestMarshalAndPatchSignalsNil(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+	if err := sse.MarshalAndPatchSignals(nil); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !strings.Contains(w.Body.String(), "data: signals null\n") {
+		t.Errorf("Expected a null payload, got: %q", w.Body.String())
+	}
+}
+
+type taggedUser struct {
+	ID       int64  `datastar:"id,readonly"`
+	Name     string `json:"name"`
+	Password string `datastar:",private"`
+}
+
+type taggedPage struct {
+	Users  []taggedUser          `json:"users"`
+	Admins []*taggedUser         `json:"admins"`
+	ByName map[string]taggedUser `json:"byName"`
+}
+
+func TestMarshalAndPatchSignalsTagsElements(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+
+	u := taggedUser{ID: 1, Name: "bob", Password: "secret"}
+	p := taggedPage{
+		Users:  []taggedUser{u},
+		Admins: []*taggedUser{&u, nil},
+		ByName: map[string]taggedUser{"bob": u},
+	}
+	if err := sse.MarshalAndPatchSignals(p); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	want := `data: signals {"admins":[{"id":1,"name":"bob"},null],"byName":{"bob":{"id":1,"name":"bob"}},"users":[{"id":1,"name":"bob"}]}` + "\n"
+	if !strings.Contains(w.Body.String(), want) {
+		t.Errorf("Expected private fields left out of elements, got: %q", w.Body.String())
+	}
+
+	attr, err := SignalsAttr(p)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if strings.Contains(string(attr), "secret") {
+		t.Errorf("Expected private fields left out of attributes, got: %q", attr)
+	}
+}
+
+func TestReadSignalsTagsElements(t *testing.T) {
+	body := `{"users":[{"id":99,"name":"alice","Password":"evil"},{"id":98,"name":"new"}],` +
+		`"admins":[{"id":99,"name":"carol"}],` +
+		`"byName":{"bob":{"id":99,"name":"robert"},"dave":{"id":97,"name":"dave"}}}`
+
+	p := taggedPage{
+		Users:  []taggedUser{{ID: 1, Name: "bob", Password: "s1"}},
+		Admins: []*taggedUser{{ID: 2, Name: "carl", Password: "s2"}},
+		ByName: map[string]taggedUser{"bob": {ID: 3, Name: "bob", Password: "s3"}},
+	}
+	r := httptest.NewRequest("POST", "/test", strings.NewReader(body))
+	if err := ReadSignals(r, &p); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(p.Users) != 2 || p.Users[0] != (taggedUser{ID: 1, Name: "alice", Password: "s1"}) || p.Users[1] != (taggedUser{Name: "new"}) {
+		t.Errorf("Expected read-only and private slice element fields untouched, got: %+v", p.Users)
+	}
+	if len(p.Admins) != 1 || *p.Admins[0] != (taggedUser{ID: 2, Name: "carol", Password: "s2"}) {
+		t.Errorf("Expected read-only and private pointer element fields untouched, got: %+v", p.Admins)
+	}
+	if p.ByName["bob"] != (taggedUser{ID: 3, Name: "robert", Password: "s3"}) || p.ByName["dave"] != (taggedUser{Name: "dave"}) {
+		t.Errorf("Expected read-only and private map element fields untouched, got: %+v", p.ByName)
+	}
+
+	r = httptest.NewRequest("POST", "/test", strings.NewReader(`{"users":null,"byName":null}`))
+	if err := ReadSignals(r, &p); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if p.Users != nil || p.ByName != nil {
+		t.Errorf("Expected null to clear slices and maps, got: %+v", p)
+	}
+}
+
+func TestReadSignalsTagsCaseInsensitive(t *testing.T) {
+	type partlyTagged struct {
+		Name    string
+		Session string `datastar:",private"`
+	}
+
+	r := httptest.NewRequest("POST", "/test", strings.NewReader(`{"name":"bob","SESSION":"evil"}`))
+	v, err := ReadSignalsOpts[partlyTagged](r)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if v.Name != "bob" || v.Session != "" {
+		t.Errorf("Expected names matched case-insensitively, got: %+v", v)
+	}
+}
+
+func TestReadSignalsRejectReadOnly(t *testing.T) {
+	read := func(body string, v *taggedView, opts ...ReadSignalsOption) error {
+		r := httptest.NewRequest("POST", "/test", strings.NewReader(body))
+		return ReadSignalsInto(r, v, opts...)
+	}
+
+	v := taggedView{UserID: 7}
+	if err := read(`{"userId":7,"title":"new"}`, &v, WithRejectReadOnlySignals()); err != nil {
+		t.Fatalf("Expected an unchanged read-only signal to be accepted, got: %v", err)
+	}
+	if v.UserID != 7 || v.Title != "new" {
+		t.Errorf("Expected writable fields set, got: %+v", v)
+	}
+
+	if err := read(`{"userId":99}`, &v, WithRejectReadOnlySignals()); !errors.Is(err, ErrSignalsInvalid) {
+		t.Errorf("Expected ErrSignalsInvalid for a changed read-only signal, got: %v", err)
+	}
+	if err := read(`{"userId":99}`, &v); err != nil || v.UserID != 7 {
+		t.Errorf("Expected the change discarded without the option, got: %+v %v", v, err)
+	}
+
+	p := taggedPage{Users: []taggedUser{{ID: 1, Name: "bob", Password: "s"}}}
+	r := httptest.NewRequest("POST", "/test", strings.NewReader(`{"users":[{"id":1,"name":"alice"},{"id":2,"name":"eve"}]}`))
+	if err := ReadSignalsInto(r, &p, WithRejectReadOnlySignals()); !errors.Is(err, ErrSignalsInvalid) {
+		t.Errorf("Expected ErrSignalsInvalid for a read-only signal of a new element, got: %v", err)
+	}
+}
+
+type jsonRulesA struct {
+	Shared string `json:"shared"`
+	Dup    string
+	Deep   string
+}
+
+type jsonRulesB struct {
+	Shared string `json:"shared"`
+	Dup    string `json:"dup"`
+	Inner  jsonRulesInner
+}
+
+type jsonRulesInner struct {
+	Deep string
+}
+
+type jsonRulesView struct {
+	jsonRulesA
+	*jsonRulesB
+	Marker  bool              `datastar:",readonly"`
+	Deep    string            `json:"deepest,omitempty"`
+	Count   int               `json:"count,string"`
+	Ptr     *float64          `json:"ptr,string"`
+	Flag    bool              `json:",string"`
+	Text    string            `json:"text,string"`
+	Tags    []string          `json:"tags,omitempty"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+	Nested  jsonRulesInner    `json:"nested,omitempty"`
+	When    time.Time         `json:"when,omitzero"`
+	Skipped *int              `json:"skipped,omitempty"`
+}
+
+func TestMarshalSignalsMatchesJSON(t *testing.T) {
+	f := 1.5
+	for _, v := range []jsonRulesView{
+		{},
+		{
+			jsonRulesA: jsonRulesA{Shared: "a", Dup: "a", Deep: "a"},
+			jsonRulesB: &jsonRulesB{Shared: "b", Dup: "b", Inner: jsonRulesInner{Deep: "b"}},
+			Marker:     true,
+			Deep:       "c",
+			Count:      3,
+			Ptr:        &f,
+			Flag:       true,
+			Text:       `say "<hi>"`,
+			Tags:       []string{},
+			Attrs:      map[string]string{},
+			When:       time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+	} {
+		patch, _, err := marshalSignals(DefaultJSONCodec, &v)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		want, err := json.Marshal(&v)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		var got, expected any
+		if err := json.Unmarshal(patch, &got); err != nil {
+			t.Fatalf("Expected valid JSON, got: %v", err)
+		}
+		if err := json.Unmarshal(want, &expected); err != nil {
+			t.Fatalf("Expected valid JSON, got: %v", err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %s, got: %s", want, patch)
+		}
+	}
+}
+
+func TestReadSignalsMatchesJSON(t *testing.T) {
+	body := `{"shared":"s","dup":"d","Deep":"x","count":"4","ptr":"2.5","Flag":"true","text":"\"q\"","tags":["t"],"Marker":true}`
+
+	// embedded pointers to unexported structs cannot be allocated
+	got := jsonRulesView{jsonRulesB: &jsonRulesB{}}
+	req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	if err := ReadSignals(req, &got); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	want := jsonRulesView{jsonRulesB: &jsonRulesB{}}
+	if err := json.Unmarshal([]byte(body), &want); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	// read-only signals are never set from the request
+	want.Marker = false
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got: %+v", want, got)
+	}
+
+	req = httptest.NewRequest("POST", "/", strings.NewReader(`{"count":4}`))
+	if err := ReadSignals(req, &got); !errors.Is(err, ErrSignalsInvalid) {
+		t.Errorf("Expected ErrSignalsInvalid for an unquoted count, got: %v", err)
+	}
+}
+
+func TestReadSignalsUnexportedEmbeddedPointer(t *testing.T) {
+	var got jsonRulesView
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"dup":"d"}`))
+	if err := ReadSignals(req, &got); !errors.Is(err, ErrSignalsInvalid) {
+		t.Errorf("Expected ErrSignalsInvalid, got: %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package datastar
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// SignalsTracker remembers the signals last sent to a stream and patches only
+// what changed. Each call to [SignalsTracker.Patch] sends the minimal
+// [JSON merge patch] that turns the previous state into the new one:
+// changed values are sent, removed keys are sent as null,
+// and arrays are replaced as a whole, matching the client's merge.
+//
+// Fields tagged ifmissing, see [SignalTagName], are not tracked: they are
+// sent with [WithOnlyIfMissing] whenever their values change, so they never
+// overwrite what the client holds.
+//
+// The tracker assumes it is the only writer of the signals it tracks.
+// Signals changed by the client or by other patches are not observed;
+// call [SignalsTracker.Reset] to send the full state again.
+//
+// [JSON merge patch]: https://datatracker.ietf.org/doc/html/rfc7386
+type SignalsTracker struct {
+	sse *ServerSentEventGenerator
+
+	mu        sync.Mutex
+	state     map[string]any
+	ifMissing []byte
+}
+
+// NewSignalsTracker creates a [SignalsTracker] bound to the stream.
+// The first [SignalsTracker.Patch] sends the full state.
+func NewSignalsTracker(sse *ServerSentEventGenerator) *SignalsTracker {
+	return &SignalsTracker{sse: sse}
+}
+
+// Patch marshals signals with the [JSONCodec] of the stream, which must encode
+// them to a JSON object, and sends the difference to the last state sent.
+// Nothing is sent if nothing changed. Private fields are never sent, see [SignalTagName].
+// The new state is only remembered once the patch was sent.
+func (t *SignalsTracker) Patch(signals any, opts ...PatchSignalsOption) error {
+	b, ifMissing, err := marshalSignals(t.sse.codec(), signals)
+	if err != nil {
+		return fmt.Errorf("failed to marshal signals: %w", err)
+	}
+	next := map[string]any{}
+	if b != nil {
+		if next, err = decodeSignalsState(b); err != nil {
+			return err
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	patch := next
+	if t.state != nil {
+		patch = diffSignals(t.state, next)
+	}
+	// the first patch is sent even if empty, unless only ifmissing fields follow
+	sent := len(patch) > 0 || (t.state == nil && ifMissing == nil)
+	if sent {
+		p, err := t.sse.codec().Marshal(patch)
+		if err != nil {
+			return fmt.Errorf("failed to marshal signals patch: %w", err)
+		}
+		if err := t.sse.PatchSignals(p, opts...); err != nil {
+			return fmt.Errorf("failed to patch signals: %w", err)
+		}
+	}
+	t.state = next
+
+	if ifMissing != nil && !bytes.Equal(ifMissing, t.ifMissing) {
+		if err := t.sse.PatchSignals(ifMissing, ifMissingOptions(opts, sent)...); err != nil {
+			return fmt.Errorf("failed to patch signals if missing: %w", err)
+		}
+		t.ifMissing = ifMissing
+	}
+	return nil
+}
+
+// Reset forgets the tracked state, so the next [SignalsTracker.Patch]
+// sends the full state again.
+func (t *SignalsTracker) Reset() {
+	t.mu.Lock()
+	t.state = nil
+	t.ifMissing = nil
+	t.mu.Unlock()
+}
+
+// decodeSignalsState decodes a JSON object into the state a client holds
+// after merging it: null members are dropped, since a merge deletes them.
+func decodeSignalsState(b []byte) (map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("failed to decode signals: %w", err)
+	}
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, errors.New("signals must encode to a JSON object")
+	}
+	return dropNullSignals(obj), nil
+}
+
+func dropNullSignals(obj map[string]any) map[string]any {
+	for k, v := range obj {
+		switch v := v.(type) {
+		case nil:
+			delete(obj, k)
+		case map[string]any:
+			obj[k] = dropNullSignals(v)
+		}
+	}
+	return obj
+}
+
+// diffSignals returns the merge patch turning prev into next.
+func diffSignals(prev, next map[string]any) map[string]any {
+	patch := map[string]any{}
+	for k, nv := range next {
+		pv, ok := prev[k]
+		if !ok {
+			patch[k] = nv
+			continue
+		}
+		pobj, pok := pv.(map[string]any)
+		nobj, nok := nv.(map[string]any)
+		if pok && nok {
+			if sub := diffSignals(pobj, nobj); len(sub) > 0 {
+				patch[k] = sub
+			}
+			continue
+		}
+		if !reflect.DeepEqual(pv, nv) {
+			patch[k] = nv
+		}
+	}
+	for k := range prev {
+		if _, ok := next[k]; !ok {
+			patch[k] = nil
+		}
+	}
+	return patch
+}
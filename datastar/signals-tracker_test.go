@@ -0,0 +1,186 @@
+package datastar
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSignalsTracker(t *testing.T) {
+	type profile struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+	type view struct {
+		Title   string         `json:"title"`
+		Profile profile        `json:"profile"`
+		Tags    []string       `json:"tags"`
+		Extra   map[string]int `json:"extra,omitempty"`
+		Draft   *string        `json:"draft"`
+	}
+
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+	tracker := NewSignalsTracker(sse)
+
+	steps := []struct {
+		name string
+		v    view
+		want string
+	}{
+		{
+			name: "full state first",
+			v:    view{Title: "a", Profile: profile{Name: "bob", Age: 30}, Tags: []string{"x"}, Extra: map[string]int{"n": 1}},
+			want: `{"extra":{"n":1},"profile":{"age":30,"name":"bob"},"tags":["x"],"title":"a"}`,
+		},
+		{
+			name: "nested change",
+			v:    view{Title: "a", Profile: profile{Name: "bob", Age: 31}, Tags: []string{"x"}, Extra: map[string]int{"n": 1}},
+			want: `{"profile":{"age":31}}`,
+		},
+		{
+			name: "unchanged",
+			v:    view{Title: "a", Profile: profile{Name: "bob", Age: 31}, Tags: []string{"x"}, Extra: map[string]int{"n": 1}},
+		},
+		{
+			name: "array replaced and key removed",
+			v:    view{Title: "a", Profile: profile{Name: "bob", Age: 31}, Tags: []string{"x", "y"}},
+			want: `{"extra":null,"tags":["x","y"]}`,
+		},
+		{
+			name: "null value added",
+			v:    view{Title: "a", Profile: profile{Name: "bob", Age: 31}, Tags: []string{"x", "y"}, Draft: new(string)},
+			want: `{"draft":""}`,
+		},
+		{
+			name: "null value removed",
+			v:    view{Title: "a", Profile: profile{Name: "bob", Age: 31}, Tags: []string{"x", "y"}},
+			want: `{"draft":null}`,
+		},
+	}
+
+	for _, step := range steps {
+		w.Body.Reset()
+		if err := tracker.Patch(step.v); err != nil {
+			t.Fatalf("%s: Expected no error, got: %v", step.name, err)
+		}
+		got := w.Body.String()
+		if step.want == "" {
+			if got != "" {
+				t.Errorf("%s: Expected no event, got: %q", step.name, got)
+			}
+			continue
+		}
+		if !strings.Contains(got, "data: signals "+step.want+"\n") {
+			t.Errorf("%s: Expected patch %s, got: %q", step.name, step.want, got)
+		}
+	}
+
+	tracker.Reset()
+	w.Body.Reset()
+	if err := tracker.Patch(map[string]int{"n": 1}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !strings.Contains(w.Body.String(), `data: signals {"n":1}`) {
+		t.Errorf("Expected full state after reset, got: %q", w.Body.String())
+	}
+
+	if err := tracker.Patch([]int{1}); err == nil {
+		t.Error("Expected an error for signals that are not an object")
+	}
+}
+
+func TestSignalsTrackerIfMissing(t *testing.T) {
+	type view struct {
+		Count int    `datastar:"count"`
+		Draft string `datastar:"draft,ifmissing"`
+	}
+
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+	tracker := NewSignalsTracker(sse)
+
+	if err := tracker.Patch(view{Count: 1, Draft: "server"}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	got := w.Body.String()
+	if !strings.Contains(got, "data: signals {\"count\":1}\n") {
+		t.Errorf("Expected the tracked signals without ifmissing fields, got: %q", got)
+	}
+	if !strings.Contains(got, "data: onlyIfMissing true\ndata: signals {\"draft\":\"server\"}\n") {
+		t.Errorf("Expected ifmissing fields patched only if missing, got: %q", got)
+	}
+
+	w.Body.Reset()
+	if err := tracker.Patch(view{Count: 2, Draft: "server"}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	got = w.Body.String()
+	if strings.Contains(got, "draft") {
+		t.Errorf("Expected ifmissing fields not to be sent again, got: %q", got)
+	}
+	if !strings.Contains(got, "data: signals {\"count\":2}\n") {
+		t.Errorf("Expected the changed signal, got: %q", got)
+	}
+
+	w.Body.Reset()
+	if err := tracker.Patch(view{Count: 2, Draft: "other"}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	got = w.Body.String()
+	if strings.Count(got, "event:") != 1 || !strings.Contains(got, "data: onlyIfMissing true\ndata: signals {\"draft\":\"other\"}\n") {
+		t.Errorf("Expected only an ifmissing patch, got: %q", got)
+	}
+}
+
+// rawHTMLCodec marshals without escaping HTML characters.
+type rawHTMLCodec struct{ StdJSONCodec }
+
+func (rawHTMLCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func TestSignalsTrackerCodec(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil), WithJSONCodec(rawHTMLCodec{}))
+	tracker := NewSignalsTracker(sse)
+
+	for _, html := range []string{"<a>", "<b>"} {
+		if err := tracker.Patch(map[string]string{"html": html}); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+	}
+	if got := w.Body.String(); !strings.Contains(got, `data: signals {"html":"<b>"}`) {
+		t.Errorf("Expected the difference marshaled by the codec, got: %q", got)
+	}
+}
+
+func TestSignalsTrackerOptions(t *testing.T) {
+	type view struct {
+		Count int    `datastar:"count"`
+		Draft string `datastar:"draft,ifmissing"`
+	}
+
+	w := httptest.NewRecorder()
+	tracker := NewSignalsTracker(NewSSE(w, httptest.NewRequest("GET", "/test", nil)))
+
+	opts := make([]PatchSignalsOption, 1, 4)
+	opts[0] = WithPatchSignalsEventID("7")
+	if err := tracker.Patch(view{Count: 1, Draft: "d"}, opts...); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if n := strings.Count(w.Body.String(), "id: 7\n"); n != 1 {
+		t.Errorf("Expected the event ID on the first event only, got: %q", w.Body.String())
+	}
+	if spare := opts[:2][1]; spare != nil {
+		t.Error("Expected the caller's options not to be written to")
+	}
+}
@@ -1,10 +1,11 @@
 package datastar
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
@@ -16,6 +17,7 @@ type patchSignalsOptions struct {
 	EventID       string
 	RetryDuration time.Duration
 	OnlyIfMissing bool
+	SigningKey    *SigningKey
 }
 
 // PatchSignalsOption configures one [EventTypePatchSignals] event.
@@ -49,6 +51,70 @@ func WithOnlyIfMissing(onlyIfMissing bool) PatchSignalsOption {
 // PatchSignals sends a [EventTypePatchSignals] to the client.
 // Requires a JSON-encoded payload.
 func (sse *ServerSentEventGenerator) PatchSignals(signalsContents []byte, opts ...PatchSignalsOption) error {
+	evt, err := patchSignalsEvent(opts)
+	if err != nil {
+		return err
+	}
+	if err := sse.sendWith(&evt, func(buf *bytebufferpool.ByteBuffer) error {
+		lines := dataLineWriter{buf: buf, literal: SignalsDatalineLiteral}
+		lines.openLine()
+		lines.Write(signalsContents)
+		lines.close()
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to send patch signals: %w", err)
+	}
+	return nil
+}
+
+// PatchSignalsReader is the [io.Reader] variant of [ServerSentEventGenerator.PatchSignals].
+// The JSON-encoded payload is read until [io.EOF] and written straight into the event.
+// Nothing is sent if reading fails.
+func (sse *ServerSentEventGenerator) PatchSignalsReader(r io.Reader, opts ...PatchSignalsOption) error {
+	evt, err := patchSignalsEvent(opts)
+	if err != nil {
+		return err
+	}
+	if err := sse.sendWith(&evt, func(buf *bytebufferpool.ByteBuffer) error {
+		lines := dataLineWriter{buf: buf, literal: SignalsDatalineLiteral}
+		lines.openLine()
+		if _, err := io.Copy(&lines, r); err != nil {
+			return fmt.Errorf("failed to read signals: %w", err)
+		}
+		lines.close()
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to send patch signals: %w", err)
+	}
+	return nil
+}
+
+// NewPatchSignalsEvent encodes an [EventTypePatchSignals] event once so it
+// can be sent to many streams with [ServerSentEventGenerator.SendEvent].
+// Requires a JSON-encoded payload.
+func NewPatchSignalsEvent(signalsContents []byte, opts ...PatchSignalsOption) (Event, error) {
+	evt, err := patchSignalsEvent(opts)
+	if err != nil {
+		return Event{}, err
+	}
+	e, err := newEventWith(&evt, func(buf *bytebufferpool.ByteBuffer) error {
+		lines := dataLineWriter{buf: buf, literal: SignalsDatalineLiteral}
+		lines.openLine()
+		lines.Write(signalsContents)
+		lines.close()
+		return nil
+	})
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to encode patch signals: %w", err)
+	}
+	return e, nil
+}
+
+// patchSignalsEvent translates the options of a signals patch into an event
+// holding every data line except the signals themselves. It fails if the
+// options ask for signing, which only [ServerSentEventGenerator.MarshalAndPatchSignals]
+// does, so signals are never sent unsigned by mistake.
+func patchSignalsEvent(opts []PatchSignalsOption) (serverSentEventData, error) {
 	options := &patchSignalsOptions{
 		EventID:       "",
 		RetryDuration: DefaultSseRetryDuration,
@@ -57,32 +123,20 @@ func (sse *ServerSentEventGenerator) PatchSignals(signalsContents []byte, opts .
 	for _, opt := range opts {
 		opt(options)
 	}
-
-	dataRows := make([]string, 0, 32)
-	if options.OnlyIfMissing {
-		dataRows = append(dataRows, OnlyIfMissingDatalineLiteral+strconv.FormatBool(options.OnlyIfMissing))
-	}
-	lines := bytes.SplitSeq(signalsContents, newLineBuf)
-	for line := range lines {
-		dataRows = append(dataRows, SignalsDatalineLiteral+string(line))
+	if options.SigningKey != nil {
+		return serverSentEventData{}, errSigningUnsupported
 	}
 
-	sendOptions := make([]SSEEventOption, 0, 2)
-	if options.EventID != "" {
-		sendOptions = append(sendOptions, WithSSEEventId(options.EventID))
+	evt := serverSentEventData{
+		Type:          EventTypePatchSignals,
+		EventID:       options.EventID,
+		RetryDuration: options.RetryDuration,
 	}
-	if options.RetryDuration != DefaultSseRetryDuration {
-		sendOptions = append(sendOptions, WithSSERetryDuration(options.RetryDuration))
+	if options.OnlyIfMissing {
+		evt.Data = []string{OnlyIfMissingDatalineLiteral + strconv.FormatBool(options.OnlyIfMissing)}
 	}
 
-	if err := sse.Send(
-		EventTypePatchSignals,
-		dataRows,
-		sendOptions...,
-	); err != nil {
-		return fmt.Errorf("failed to send patch signals: %w", err)
-	}
-	return nil
+	return evt, nil
 }
 
 // ReadSignals extracts Datastar signals from
@@ -90,31 +144,125 @@ func (sse *ServerSentEventGenerator) PatchSignals(signalsContents []byte, opts .
 // which should be a pointer to a struct.
 //
 // Expects signals in [URL.Query] for [http.MethodGet] requests.
-// Expects JSON-encoded signals in [Request.Body] for other request methods.
+// Expects JSON-encoded signals in [Request.Body] for other request methods,
+// or form fields for form-encoded and multipart requests; see [ReadSignalsOpts].
+// Signals cached by [SignalsMiddleware] are used instead of the request body.
+// JSON payloads are decoded with the [DefaultJSONCodec].
+// Fields tagged private or readonly with [SignalTagName] are never set.
 func ReadSignals(r *http.Request, signals any) error {
-	var dsInput []byte
+	buf := bytebufferpool.Get()
+	defer bytebufferpool.Put(buf)
+
+	rs, err := readRequestSignals(r, buf, &readSignalsOptions{})
+	if err != nil {
+		return err
+	}
+	if rs.isForm {
+		return rs.decode(signals, &readSignalsOptions{})
+	}
+	if rs.json == nil && isQuerySignalsMethod(r.Method) {
+		return nil
+	}
+	return decodeSignals(rs.json, signals, &readSignalsOptions{})
+}
+
+// requestSignals holds the raw signals of a request.
+type requestSignals struct {
+	// json is the JSON-encoded payload, nil if there is none
+	json []byte
+
+	isForm bool
+	form   url.Values
+	files  map[string][]*multipart.FileHeader
+
+	// size is the number of bytes the signals were read from
+	size int64
+	// options are the options of the [SignalsMiddleware] that cached the signals
+	options *readSignalsOptions
+}
+
+// empty reports whether the request carried no signals.
+func (rs *requestSignals) empty() bool {
+	if rs.isForm {
+		return len(rs.form) == 0 && len(rs.files) == 0
+	}
+	return rs.json == nil
+}
 
-	if r.Method == "GET" || r.Method == "DELETE" {
+// decode decodes the signals into the target.
+func (rs *requestSignals) decode(signals any, options *readSignalsOptions) error {
+	if rs.isForm {
+		if err := decodeForm(rs.form, rs.files, signals, options.DisallowUnknownFields); err != nil {
+			return fmt.Errorf("%w: %w", ErrSignalsInvalid, err)
+		}
+		return nil
+	}
+	if options.Validate != nil {
+		if err := options.Validate(rs.json); err != nil {
+			return fmt.Errorf("%w: %w", ErrSignalsInvalid, err)
+		}
+	}
+	return decodeSignals(rs.json, signals, options)
+}
+
+// readRequestSignals returns the signals cached by [SignalsMiddleware], or reads
+// them from the request. JSON body payloads are read into buf. Cached signals
+// larger than options.MaxSize fail as if they were read again.
+func readRequestSignals(r *http.Request, buf *bytebufferpool.ByteBuffer, options *readSignalsOptions) (*requestSignals, error) {
+	if rs, ok := r.Context().Value(signalsContextKey{}).(*requestSignals); ok {
+		if options.MaxSize > 0 && rs.size > options.MaxSize {
+			return nil, fmt.Errorf("%w: signals exceed %d bytes", ErrSignalsTooLarge, options.MaxSize)
+		}
+		return rs, nil
+	}
+
+	if isFormRequest(r) {
+		return parseFormSignals(r, options)
+	}
+
+	dsInput, err := readSignalsPayload(r, buf, options.MaxSize)
+	if err != nil {
+		return nil, err
+	}
+	return &requestSignals{json: dsInput, size: int64(len(dsInput))}, nil
+}
+
+// isQuerySignalsMethod reports whether the request method carries
+// signals in the query string rather than the body.
+func isQuerySignalsMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodDelete
+}
+
+// readSignalsPayload returns the raw signals of the request, or nil if there
+// are none. Body payloads are read into buf. A positive maxSize bounds the payload.
+func readSignalsPayload(r *http.Request, buf *bytebufferpool.ByteBuffer, maxSize int64) ([]byte, error) {
+	if isQuerySignalsMethod(r.Method) {
 		dsJSON := r.URL.Query().Get(DatastarKey)
 		if dsJSON == "" {
-			return nil
-		} else {
-			dsInput = []byte(dsJSON)
+			return nil, nil
 		}
-	} else {
-		buf := bytebufferpool.Get()
-		defer bytebufferpool.Put(buf)
-		if _, err := buf.ReadFrom(r.Body); err != nil {
-			if err == http.ErrBodyReadAfterClose {
-				return fmt.Errorf("body already closed, are you sure you created the SSE ***AFTER*** the ReadSignals? %w", err)
-			}
-			return fmt.Errorf("failed to read body: %w", err)
+		if maxSize > 0 && int64(len(dsJSON)) > maxSize {
+			return nil, fmt.Errorf("%w: query exceeds %d bytes", ErrSignalsTooLarge, maxSize)
 		}
-		dsInput = buf.Bytes()
+		return []byte(dsJSON), nil
 	}
 
-	if err := json.Unmarshal(dsInput, signals); err != nil {
-		return fmt.Errorf("failed to unmarshal: %w", err)
+	var body io.Reader = r.Body
+	if maxSize > 0 {
+		// read one extra byte to detect oversized payloads
+		body = io.LimitReader(r.Body, maxSize+1)
 	}
-	return nil
+	if _, err := buf.ReadFrom(body); err != nil {
+		if err == http.ErrBodyReadAfterClose {
+			return nil, fmt.Errorf("body already closed, are you sure you created the SSE ***AFTER*** the ReadSignals? %w", err)
+		}
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+	if maxSize > 0 && int64(buf.Len()) > maxSize {
+		return nil, fmt.Errorf("%w: body exceeds %d bytes", ErrSignalsTooLarge, maxSize)
+	}
+	if buf.Len() == 0 {
+		return nil, nil
+	}
+	return buf.Bytes(), nil
 }
@@ -0,0 +1,170 @@
+package datastar
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type readSignalsTarget struct {
+	ID   json.Number `json:"id"`
+	Name string      `json:"name"`
+}
+
+func TestReadSignalsOpts(t *testing.T) {
+	req := httptest.NewRequest("POST", "/test", strings.NewReader(`{"id":12345678901234567890,"name":"bob"}`))
+	signals, err := ReadSignalsOpts[readSignalsTarget](req, WithDisallowUnknownSignals(), WithUseNumber())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if signals.ID != "12345678901234567890" || signals.Name != "bob" {
+		t.Errorf("Unexpected signals: %+v", signals)
+	}
+
+	query := url.Values{DatastarKey: {`{"x":{"y":1}}`}}
+	req = httptest.NewRequest("GET", "/test?"+query.Encode(), nil)
+	generic, err := ReadSignalsOpts[map[string]any](req, WithUseNumber())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if generic["x"].(map[string]any)["y"] != json.Number("1") {
+		t.Errorf("Expected json.Number, got: %#v", generic["x"])
+	}
+}
+
+func TestReadSignalsOptsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		opts   []ReadSignalsOption
+		err    error
+	}{
+		{"too large body", "POST", "/test", `{"name":"0123456789"}`, []ReadSignalsOption{WithMaxSignalsSize(10)}, ErrSignalsTooLarge},
+		{"too large query", "GET", "/test?datastar=%7B%22name%22%3A%220123456789%22%7D", "", []ReadSignalsOption{WithMaxSignalsSize(10)}, ErrSignalsTooLarge},
+		{"missing query", "GET", "/test", "", []ReadSignalsOption{WithRequiredSignals()}, ErrSignalsMissing},
+		{"missing body", "POST", "/test", "", []ReadSignalsOption{WithRequiredSignals()}, ErrSignalsMissing},
+		{"unknown field", "POST", "/test", `{"nope":1}`, []ReadSignalsOption{WithDisallowUnknownSignals()}, ErrSignalsInvalid},
+		{"trailing data", "POST", "/test", `{"name":"a"} {}`, nil, ErrSignalsInvalid},
+		{"malformed", "POST", "/test", `{"name":`, nil, ErrSignalsInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if _, err := ReadSignalsOpts[readSignalsTarget](req, tt.opts...); !errors.Is(err, tt.err) {
+				t.Errorf("Expected %v, got: %v", tt.err, err)
+			}
+		})
+	}
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	if _, err := ReadSignalsOpts[readSignalsTarget](req); err != nil {
+		t.Errorf("Expected missing optional signals to be accepted, got: %v", err)
+	}
+}
+
+type formSignalsTarget struct {
+	Title   string                  `json:"title"`
+	Count   int                     `json:"count"`
+	Publish bool                    `json:"publish"`
+	Tags    []string                `json:"tags"`
+	Author  struct{ Name string }   `json:"author"`
+	Cover   *multipart.FileHeader   `json:"cover"`
+	Files   []*multipart.FileHeader `json:"files"`
+}
+
+func TestReadSignalsURLEncodedForm(t *testing.T) {
+	form := url.Values{
+		"title":       {"hello"},
+		"count":       {"3"},
+		"publish":     {"on"},
+		"tags":        {"a", "b"},
+		"author.Name": {"bob"},
+	}
+	req := httptest.NewRequest("POST", "/test", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var signals formSignalsTarget
+	if err := ReadSignals(req, &signals); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if signals.Title != "hello" || signals.Count != 3 || !signals.Publish || len(signals.Tags) != 2 || signals.Author.Name != "bob" {
+		t.Errorf("Unexpected signals: %+v", signals)
+	}
+}
+
+type formAudit struct {
+	CreatedBy string `json:"createdBy"`
+	Note      string `json:"note"`
+}
+
+type formEmbedded struct {
+	formAudit
+	*formSignalsTarget
+	Note string `json:"note"`
+}
+
+func TestReadSignalsFormEmbedded(t *testing.T) {
+	form := url.Values{
+		"createdBy": {"ann"},
+		"note":      {"outer"},
+		"title":     {"hello"},
+	}
+	req := httptest.NewRequest("POST", "/test", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	signals := formEmbedded{formSignalsTarget: &formSignalsTarget{}}
+	if err := ReadSignalsInto(req, &signals, WithDisallowUnknownSignals()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if signals.CreatedBy != "ann" || signals.Note != "outer" || signals.formAudit.Note != "" || signals.Title != "hello" {
+		t.Errorf("Expected embedded fields to be promoted like encoding/json, got: %+v", signals)
+	}
+
+	form = url.Values{"formAudit.createdBy": {"ann"}}
+	req = httptest.NewRequest("POST", "/test", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := ReadSignalsInto(req, &signals, WithDisallowUnknownSignals()); err == nil {
+		t.Error("Expected an error for a prefixed embedded field")
+	}
+}
+
+func TestReadSignalsMultipartForm(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "upload")
+	for _, name := range []string{"cover", "files", "files"} {
+		fw, _ := mw.CreateFormFile(name, name+".txt")
+		fw.Write([]byte("content of " + name))
+	}
+	mw.Close()
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest("POST", "/test", bytes.NewReader(body.Bytes()))
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		return req
+	}
+
+	signals, err := ReadSignalsOpts[formSignalsTarget](newRequest(), WithRequiredSignals())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if signals.Title != "upload" || signals.Cover == nil || signals.Cover.Filename != "cover.txt" || len(signals.Files) != 2 {
+		t.Errorf("Unexpected signals: %+v", signals)
+	}
+
+	if _, err := ReadSignalsOpts[formSignalsTarget](newRequest(), WithMaxSignalsSize(64)); !errors.Is(err, ErrSignalsTooLarge) {
+		t.Errorf("Expected ErrSignalsTooLarge, got: %v", err)
+	}
+	if _, err := ReadSignalsOpts[struct{ Title string }](newRequest(), WithDisallowUnknownSignals()); !errors.Is(err, ErrSignalsInvalid) {
+		t.Errorf("Expected ErrSignalsInvalid for unknown fields, got: %v", err)
+	}
+}
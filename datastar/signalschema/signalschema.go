@@ -0,0 +1,69 @@
+// Package signalschema validates Datastar signals against the JSON Schema
+// that the datastar package generates for their type, see
+// datastar.WriteSignalsSchema. It is kept apart from the datastar package so
+// programs that do not validate signals do not link a JSON Schema compiler.
+package signalschema
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+// schemas caches the compiled schema of each type passed to [ValidateSignals].
+var schemas sync.Map // map[reflect.Type]*jsonschema.Schema
+
+// compiledSchema returns the compiled JSON Schema of the signal type T.
+func compiledSchema[T any]() (*jsonschema.Schema, error) {
+	t := reflect.TypeFor[T]()
+	if sch, ok := schemas.Load(t); ok {
+		return sch.(*jsonschema.Schema), nil
+	}
+
+	var signals T
+	raw, err := datastar.SignalsSchema(signals)
+	if err != nil {
+		return nil, err
+	}
+	// the compiler expects documents as decoded by its own JSON decoder
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	const url = "datastar-signals.json"
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(url, doc); err != nil {
+		return nil, err
+	}
+	sch, err := c.Compile(url)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := schemas.LoadOrStore(t, sch)
+	return actual.(*jsonschema.Schema), nil
+}
+
+// ValidateSignals is the variant of [datastar.ReadSignalsOpts] that first
+// checks the JSON payload against the schema of T, which must be a struct.
+// Payloads that do not match fail with [datastar.ErrSignalsInvalid] and are
+// not decoded. Form-encoded requests are decoded without validation.
+func ValidateSignals[T any](r *http.Request, opts ...datastar.ReadSignalsOption) (T, error) {
+	sch, err := compiledSchema[T]()
+	if err != nil {
+		var zero T
+		return zero, fmt.Errorf("failed to compile signals schema: %w", err)
+	}
+	validate := func(payload []byte) error {
+		instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		return sch.Validate(instance)
+	}
+	return datastar.ReadSignalsOpts[T](r, append(opts[:len(opts):len(opts)], datastar.WithSignalsValidator(validate))...)
+}
@@ -0,0 +1,54 @@
+package signalschema
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/starfederation/datastar-go/datastar"
+)
+
+type profile struct {
+	Name string   `json:"name"`
+	Next *profile `json:"next"`
+}
+
+type counter struct {
+	Count   int      `json:"count"`
+	UserID  int64    `datastar:"userId,readonly"`
+	Tags    []string `json:"tags"`
+	Profile profile  `json:"profile"`
+}
+
+func TestValidateSignals(t *testing.T) {
+	r := httptest.NewRequest("POST", "/test", strings.NewReader(`{"count":2,"userId":9,"tags":["a"],"profile":{"name":"x","next":null},"other":true}`))
+	v, err := ValidateSignals[counter](r)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if v.Count != 2 || v.Profile.Name != "x" || v.UserID != 0 {
+		t.Errorf("Expected signals decoded without read-only fields, got: %+v", v)
+	}
+
+	for _, body := range []string{
+		`{"count":"2"}`,
+		`{"count":1.5}`,
+		`{"profile":{"next":{"name":3}}}`,
+		`{"tags":"a"}`,
+	} {
+		r := httptest.NewRequest("POST", "/test", strings.NewReader(body))
+		if _, err := ValidateSignals[counter](r); !errors.Is(err, datastar.ErrSignalsInvalid) {
+			t.Errorf("Expected ErrSignalsInvalid for %s, got: %v", body, err)
+		}
+	}
+
+	r = httptest.NewRequest("POST", "/test", strings.NewReader(`{"count":1,"other":true}`))
+	if _, err := ValidateSignals[counter](r, datastar.WithDisallowUnknownSignals()); !errors.Is(err, datastar.ErrSignalsInvalid) {
+		t.Errorf("Expected read options applied, got: %v", err)
+	}
+
+	if _, err := ValidateSignals[map[string]any](httptest.NewRequest("POST", "/test", nil)); err == nil {
+		t.Error("Expected an error for a non-struct type")
+	}
+}
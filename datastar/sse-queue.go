@@ -0,0 +1,249 @@
+package datastar
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// OverflowPolicy decides what happens when an event is sent
+// while the send queue is full.
+type OverflowPolicy string
+
+const (
+	// OverflowBlock makes the sender wait until the queue has room.
+	OverflowBlock OverflowPolicy = "block"
+
+	// OverflowDropOldest discards the oldest queued event.
+	OverflowDropOldest OverflowPolicy = "drop_oldest"
+
+	// OverflowCoalesceSelector replaces a queued elements patch that targets
+	// the same selector with the same morphing mode, keeping its place in the
+	// queue. If there is none, the sender waits as with [OverflowBlock].
+	OverflowCoalesceSelector OverflowPolicy = "coalesce_selector"
+
+	// OverflowDisconnect closes the stream and fails the send with [ErrSendQueueFull].
+	OverflowDisconnect OverflowPolicy = "disconnect"
+)
+
+// ErrSendQueueFull is returned, and set as the stream context cause,
+// when the [OverflowDisconnect] policy closes a stream.
+var ErrSendQueueFull = errors.New("send queue full")
+
+// sendQueueOptions holds the configuration data for [SendQueueOption]s.
+type sendQueueOptions struct {
+	OverflowPolicy OverflowPolicy
+}
+
+// SendQueueOption configures the send queue created by [WithSendQueue].
+type SendQueueOption func(*sendQueueOptions)
+
+// WithOverflowPolicy overrides the default [OverflowBlock] policy.
+func WithOverflowPolicy(policy OverflowPolicy) SendQueueOption {
+	return func(o *sendQueueOptions) {
+		o.OverflowPolicy = policy
+	}
+}
+
+// WithSendQueue moves network writes to a dedicated goroutine fed by a queue
+// holding up to size events. [ServerSentEventGenerator.Send] then only encodes
+// and enqueues, so a slow client no longer blocks the goroutines sending to it.
+// Write errors close the stream and are returned by later sends.
+//
+// Senders waiting for room do not hold the stream, so
+// [ServerSentEventGenerator.Close] and the heartbeat are never held up by
+// them; Close wakes them with an error. Handlers must `defer sse.Close()`
+// so queued events are written while the response is still valid, and the
+// writer has exited once it returns.
+// Combine with [WithWriteTimeout] to detect stuck connections.
+func WithSendQueue(size int, opts ...SendQueueOption) SSEOption {
+	return func(sse *ServerSentEventGenerator) {
+		options := &sendQueueOptions{
+			OverflowPolicy: OverflowBlock,
+		}
+		for _, opt := range opts {
+			opt(options)
+		}
+		if size < 1 {
+			size = 1
+		}
+		sse.queue = &sendQueue{
+			mu:     sse.mu,
+			cond:   sync.NewCond(sse.mu),
+			size:   size,
+			policy: options.OverflowPolicy,
+			done:   make(chan struct{}),
+		}
+	}
+}
+
+type queuedEvent struct {
+	data []byte
+	// key identifies events that supersede each other, empty if none do
+	key string
+}
+
+// sendQueue is a bounded FIFO of encoded events drained by one writer goroutine.
+type sendQueue struct {
+	// mu is the mutex of the stream, so senders waiting on cond release it
+	mu     *sync.Mutex
+	cond   *sync.Cond
+	items  []queuedEvent
+	size   int
+	policy OverflowPolicy
+	err    error
+	closed bool
+	done   chan struct{}
+	cancel context.CancelCauseFunc
+}
+
+// start launches the writer goroutine. The queue fails once ctx is done.
+func (q *sendQueue) start(sse *ServerSentEventGenerator, cancel context.CancelCauseFunc) {
+	q.cancel = cancel
+	context.AfterFunc(sse.ctx, func() {
+		q.fail(context.Cause(sse.ctx))
+	})
+
+	go func() {
+		defer close(q.done)
+		for {
+			evt, ok := q.pop()
+			if !ok {
+				return
+			}
+			if err := sse.writeLocked(evt.data); err != nil {
+				q.fail(err)
+				q.cancel(err)
+				return
+			}
+		}
+	}()
+}
+
+// reserveLocked waits until the queue can take an event with the key,
+// applying the overflow policy when it is full. It returns the index of the
+// queued event the new one replaces, or -1 if it is appended. The caller must
+// hold the stream mutex, which is released while waiting.
+func (q *sendQueue) reserveLocked(key string) (int, error) {
+	for len(q.items) >= q.size && q.err == nil && !q.closed {
+		switch q.policy {
+		case OverflowDropOldest:
+			q.items = q.items[1:]
+			continue
+		case OverflowDisconnect:
+			q.err = ErrSendQueueFull
+			q.cond.Broadcast()
+			q.cancel(ErrSendQueueFull)
+			return -1, ErrSendQueueFull
+		case OverflowCoalesceSelector:
+			if i := q.indexLocked(key); i >= 0 {
+				return i, nil
+			}
+		}
+		q.cond.Wait()
+	}
+
+	if q.err != nil {
+		return -1, q.err
+	}
+	if q.closed {
+		return -1, errStreamClosed
+	}
+	return -1, nil
+}
+
+// putLocked stores an event at the index returned by reserveLocked,
+// which the caller must not have released the stream mutex since.
+func (q *sendQueue) putLocked(evt queuedEvent, at int) {
+	if at >= 0 {
+		q.items[at] = evt
+	} else {
+		q.items = append(q.items, evt)
+	}
+	q.cond.Broadcast()
+}
+
+// tryPushLocked enqueues the event only if the queue has room.
+// The caller must hold the stream mutex.
+func (q *sendQueue) tryPushLocked(evt queuedEvent) {
+	if len(q.items) < q.size && q.err == nil && !q.closed {
+		q.items = append(q.items, evt)
+		q.cond.Broadcast()
+	}
+}
+
+func (q *sendQueue) indexLocked(key string) int {
+	if key == "" {
+		return -1
+	}
+	for i, item := range q.items {
+		if item.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// pop waits for the next event. It returns false once the queue has
+// failed, or has been closed and drained.
+func (q *sendQueue) pop() (queuedEvent, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.items) == 0 && q.err == nil && !q.closed {
+		q.cond.Wait()
+	}
+	if q.err != nil || len(q.items) == 0 {
+		return queuedEvent{}, false
+	}
+	evt := q.items[0]
+	q.items = q.items[1:]
+	q.cond.Broadcast()
+	return evt, true
+}
+
+func (q *sendQueue) fail(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.err == nil {
+		q.err = err
+	}
+	q.cond.Broadcast()
+}
+
+// close stops accepting events and waits for the writer to drain the queue.
+func (q *sendQueue) close() error {
+	q.mu.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+
+	<-q.done
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.err
+}
+
+// coalesceKey returns the key of elements patches that fully replace their
+// target, so a newer one makes a queued older one obsolete.
+func coalesceKey(evt *serverSentEventData) string {
+	if evt.Type != EventTypePatchElements {
+		return ""
+	}
+	selector, mode := "", string(DefaultElementPatchMode)
+	for _, line := range evt.Data {
+		if s, ok := strings.CutPrefix(line, SelectorDatalineLiteral); ok {
+			selector = s
+		} else if m, ok := strings.CutPrefix(line, ModeDatalineLiteral); ok {
+			mode = m
+		}
+	}
+	switch ElementPatchMode(mode) {
+	case ElementPatchModeOuter, ElementPatchModeInner, ElementPatchModeReplace:
+		if selector != "" {
+			return mode + NewLine + selector
+		}
+	}
+	return ""
+}
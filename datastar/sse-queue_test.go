@@ -0,0 +1,285 @@
+package datastar
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedRecorder blocks every write until the gate is opened.
+type gatedRecorder struct {
+	*httptest.ResponseRecorder
+	mu     sync.Mutex
+	writes int
+	gate   chan struct{}
+}
+
+func newGatedRecorder() *gatedRecorder {
+	return &gatedRecorder{
+		ResponseRecorder: httptest.NewRecorder(),
+		gate:             make(chan struct{}),
+	}
+}
+
+func (g *gatedRecorder) Write(b []byte) (int, error) {
+	<-g.gate
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.writes++
+	return g.ResponseRecorder.Write(b)
+}
+
+func (g *gatedRecorder) body() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.Body.String()
+}
+
+// fillQueue sends a first event that blocks the writer, then fills the queue.
+func fillQueue(t *testing.T, sse *ServerSentEventGenerator, selectors ...string) {
+	t.Helper()
+	for _, selector := range selectors {
+		if err := sse.PatchElements("<div>"+selector+"</div>", WithSelector(selector)); err != nil {
+			t.Fatalf("Expected no error when sending, got: %v", err)
+		}
+	}
+}
+
+// waitForWriter waits until the writer goroutine has taken every queued
+// event, failing the test if that does not happen within a generous deadline.
+func waitForWriter(t *testing.T, sse *ServerSentEventGenerator) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sse.queue.mu.Lock()
+		n := len(sse.queue.items)
+		sse.queue.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the writer, %d events still queued", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSendQueueDropOldest(t *testing.T) {
+	w := newGatedRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil), WithSendQueue(2, WithOverflowPolicy(OverflowDropOldest)))
+
+	fillQueue(t, sse, "#blocked")
+	waitForWriter(t, sse)
+	fillQueue(t, sse, "#a", "#b", "#c")
+	close(w.gate)
+
+	if err := sse.Close(); err != nil {
+		t.Fatalf("Expected no error closing, got: %v", err)
+	}
+	body := w.body()
+	if strings.Contains(body, "#a") || !strings.Contains(body, "#b") || !strings.Contains(body, "#c") {
+		t.Errorf("Expected the oldest queued event to be dropped, got: %q", body)
+	}
+}
+
+func TestSendQueueCoalesceSelector(t *testing.T) {
+	w := newGatedRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil), WithSendQueue(2, WithOverflowPolicy(OverflowCoalesceSelector)))
+
+	fillQueue(t, sse, "#blocked")
+	waitForWriter(t, sse)
+	fillQueue(t, sse, "#a", "#b")
+	if err := sse.PatchElements("<div>latest</div>", WithSelector("#a")); err != nil {
+		t.Fatalf("Expected no error when sending, got: %v", err)
+	}
+	close(w.gate)
+
+	if err := sse.Close(); err != nil {
+		t.Fatalf("Expected no error closing, got: %v", err)
+	}
+	body := w.body()
+	if strings.Contains(body, "<div>#a</div>") || !strings.Contains(body, "latest") {
+		t.Errorf("Expected the queued patch for #a to be replaced, got: %q", body)
+	}
+	if strings.Index(body, "latest") > strings.Index(body, "<div>#b</div>") {
+		t.Errorf("Expected the replacement to keep the place of the replaced patch, got: %q", body)
+	}
+}
+
+func TestSendQueueBlockReleasesStream(t *testing.T) {
+	w := newGatedRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil), WithSendQueue(1, WithOverflowPolicy(OverflowBlock)))
+
+	fillQueue(t, sse, "#blocked")
+	waitForWriter(t, sse)
+	fillQueue(t, sse, "#a")
+
+	sent := make(chan error, 1)
+	go func() {
+		sent <- sse.PatchElements("<div>#b</div>", WithSelector("#b"))
+	}()
+	select {
+	case err := <-sent:
+		t.Fatalf("Expected the send to wait for room in the queue, got: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- sse.Close() }()
+	select {
+	case err := <-sent:
+		if !errors.Is(err, errStreamClosed) {
+			t.Errorf("Expected Close to fail the waiting send, got: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Close not to wait for the blocked sender")
+	}
+	close(w.gate)
+
+	if err := <-closed; err != nil {
+		t.Fatalf("Expected no error closing, got: %v", err)
+	}
+	if body := w.body(); strings.Contains(body, "<div>#b</div>") || !strings.Contains(body, "<div>#a</div>") {
+		t.Errorf("Expected the queued events written and the failed one dropped, got: %q", body)
+	}
+}
+
+func TestSendQueueDisconnect(t *testing.T) {
+	w := newGatedRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil), WithSendQueue(1, WithOverflowPolicy(OverflowDisconnect)))
+
+	fillQueue(t, sse, "#blocked")
+	waitForWriter(t, sse)
+	fillQueue(t, sse, "#a")
+	if err := sse.PatchElements("<div></div>", WithSelector("#b")); !errors.Is(err, ErrSendQueueFull) {
+		t.Errorf("Expected ErrSendQueueFull, got: %v", err)
+	}
+	close(w.gate)
+
+	if err := sse.Close(); !errors.Is(err, ErrSendQueueFull) {
+		t.Errorf("Expected close to report ErrSendQueueFull, got: %v", err)
+	}
+	if !sse.IsClosed() || !errors.Is(context.Cause(sse.Context()), ErrSendQueueFull) {
+		t.Errorf("Expected the stream context to be cancelled with ErrSendQueueFull")
+	}
+}
+
+func TestSendQueueClose(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil), WithSendQueue(4))
+
+	for range 10 {
+		if err := sse.PatchElements(`<div id="a"></div>`); err != nil {
+			t.Fatalf("Expected no error when sending, got: %v", err)
+		}
+	}
+	if err := sse.Close(); err != nil {
+		t.Fatalf("Expected no error closing, got: %v", err)
+	}
+	if got := strings.Count(w.Body.String(), "event: "); got != 10 {
+		t.Errorf("Expected 10 events written before Close returned, got: %d", got)
+	}
+	if err := sse.PatchElements(`<div id="a"></div>`); err == nil {
+		t.Error("Expected an error when sending after Close")
+	}
+}
+
+func TestSendQueueBlock(t *testing.T) {
+	w := newGatedRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil), WithSendQueue(1, WithOverflowPolicy(OverflowBlock)))
+
+	fillQueue(t, sse, "#blocked")
+	waitForWriter(t, sse)
+	fillQueue(t, sse, "#a")
+
+	sent := make(chan error, 1)
+	go func() {
+		sent <- sse.PatchElements("<div>#b</div>", WithSelector("#b"))
+	}()
+	select {
+	case err := <-sent:
+		t.Fatalf("Expected the send to wait for room in the queue, got: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(w.gate)
+
+	if err := <-sent; err != nil {
+		t.Fatalf("Expected no error once the queue had room, got: %v", err)
+	}
+	if err := sse.Close(); err != nil {
+		t.Fatalf("Expected no error closing, got: %v", err)
+	}
+	body := w.body()
+	blocked, a, b := strings.Index(body, "<div>#blocked</div>"), strings.Index(body, "<div>#a</div>"), strings.Index(body, "<div>#b</div>")
+	if blocked < 0 || blocked > a || a > b {
+		t.Errorf("Expected every event to be written in order, got: %q", body)
+	}
+}
+
+// stuckRecorder simulates a stuck connection: every write waits for
+// the write deadline and then fails, as a net.Conn would.
+type stuckRecorder struct {
+	*httptest.ResponseRecorder
+	mu        sync.Mutex
+	deadline  time.Time
+	deadlines []time.Time
+}
+
+func (s *stuckRecorder) SetWriteDeadline(deadline time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deadline = deadline
+	s.deadlines = append(s.deadlines, deadline)
+	return nil
+}
+
+func (s *stuckRecorder) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	deadline := s.deadline
+	s.mu.Unlock()
+	if deadline.IsZero() {
+		return s.ResponseRecorder.Write(b)
+	}
+	time.Sleep(time.Until(deadline))
+	return 0, os.ErrDeadlineExceeded
+}
+
+func TestWriteTimeout(t *testing.T) {
+	w := &stuckRecorder{ResponseRecorder: httptest.NewRecorder()}
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil), WithWriteTimeout(10*time.Millisecond))
+
+	start := time.Now()
+	err := sse.PatchElements(`<div id="a"></div>`)
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Expected the write to fail with a deadline error, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the write to fail after the timeout, took: %v", elapsed)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.deadlines) != 2 || w.deadlines[0].Before(start) || !w.deadlines[1].IsZero() {
+		t.Errorf("Expected a deadline set for the write and cleared after it, got: %v", w.deadlines)
+	}
+}
+
+func TestWriteTimeoutSendQueue(t *testing.T) {
+	w := &stuckRecorder{ResponseRecorder: httptest.NewRecorder()}
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil), WithSendQueue(4), WithWriteTimeout(10*time.Millisecond))
+
+	if err := sse.PatchElements(`<div id="a"></div>`); err != nil {
+		t.Fatalf("Expected no error when enqueueing, got: %v", err)
+	}
+	if err := sse.Close(); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Expected close to report the deadline error, got: %v", err)
+	}
+	if !sse.IsClosed() || !errors.Is(context.Cause(sse.Context()), os.ErrDeadlineExceeded) {
+		t.Errorf("Expected the stream context to be cancelled by the failed write")
+	}
+}
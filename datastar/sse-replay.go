@@ -0,0 +1,361 @@
+package datastar
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// ReplayStore keeps recently sent events so they can be replayed to a client
+// that reconnects with a [Last-Event-ID] header.
+// Implementations must be safe for concurrent use.
+//
+// [Last-Event-ID]: https://html.spec.whatwg.org/multipage/server-sent-events.html#the-last-event-id-header
+type ReplayStore interface {
+	// Append stores one event under its ID and returns the stored encoding.
+	// An empty id asks the store for the next ID of its sequence, assigned
+	// under the same lock that orders the stored events, so streams sharing
+	// the store cannot store events out of ID order. encode returns the fully
+	// encoded event for the final ID; the store may retain it.
+	// Once the event is stored, Append must not fail.
+	Append(id string, encode func(id string) ([]byte, error)) ([]byte, error)
+	// Since returns the encoded events stored after the event with lastEventID,
+	// oldest first. If lastEventID is no longer stored, every stored event is returned.
+	Since(lastEventID string) ([][]byte, error)
+}
+
+// ErrReplayFailed is reported by every send on a stream whose missed
+// events could not be replayed, so the handler can end the stream and
+// let the client reconnect with the same Last-Event-ID.
+var ErrReplayFailed = errors.New("failed to replay missed events")
+
+// WithReplay stores every event sent on the stream in the [ReplayStore].
+// Events sent without an event ID are assigned one by the store.
+// When the request carries a Last-Event-ID header, the events missed
+// by the client are replayed before [NewSSE] returns. If that fails,
+// every send on the stream returns an error wrapping [ErrReplayFailed].
+//
+// The store must outlive the request, so keep one store per logical stream
+// (for example per user session) and pass it to every reconnecting request.
+func WithReplay(store ReplayStore) SSEOption {
+	return func(sse *ServerSentEventGenerator) {
+		sse.replay = store
+	}
+}
+
+// replayLocked writes the events missed since lastEventID.
+// The caller must hold the generator mutex.
+func (sse *ServerSentEventGenerator) replayLocked(lastEventID string) error {
+	events, err := sse.replay.Since(lastEventID)
+	if err != nil {
+		return fmt.Errorf("failed to read replay store: %w", err)
+	}
+	for _, evt := range events {
+		if err := sse.writeLocked(evt); err != nil {
+			return fmt.Errorf("failed to replay event: %w", err)
+		}
+	}
+	return nil
+}
+
+// maxReplayEntrySize guards against loading a corrupt record length.
+const maxReplayEntrySize = 64 << 20
+
+type replayEntry struct {
+	id    string
+	event []byte
+}
+
+// replayRing is a bounded ring of encoded events.
+type replayRing struct {
+	entries []replayEntry
+	start   int
+	count   int
+}
+
+func newReplayRing(size int) replayRing {
+	if size < 1 {
+		size = 1
+	}
+	return replayRing{entries: make([]replayEntry, size)}
+}
+
+func (r *replayRing) push(e replayEntry) {
+	if r.count < len(r.entries) {
+		r.entries[(r.start+r.count)%len(r.entries)] = e
+		r.count++
+		return
+	}
+	r.entries[r.start] = e
+	r.start = (r.start + 1) % len(r.entries)
+}
+
+func (r *replayRing) since(lastEventID string) [][]byte {
+	from := 0
+	for i := r.count - 1; i >= 0; i-- {
+		if r.entries[(r.start+i)%len(r.entries)].id == lastEventID {
+			from = i + 1
+			break
+		}
+	}
+	events := make([][]byte, 0, r.count-from)
+	for i := from; i < r.count; i++ {
+		events = append(events, r.entries[(r.start+i)%len(r.entries)].event)
+	}
+	return events
+}
+
+func (r *replayRing) all() []replayEntry {
+	entries := make([]replayEntry, 0, r.count)
+	for i := range r.count {
+		entries = append(entries, r.entries[(r.start+i)%len(r.entries)])
+	}
+	return entries
+}
+
+// MemoryReplayStore is a [ReplayStore] holding the most recent events in memory.
+type MemoryReplayStore struct {
+	mu   sync.Mutex
+	seq  uint64
+	ring replayRing
+}
+
+// NewMemoryReplayStore creates a [MemoryReplayStore] that keeps at most size events.
+func NewMemoryReplayStore(size int) *MemoryReplayStore {
+	return &MemoryReplayStore{ring: newReplayRing(size)}
+}
+
+// Append stores the event, evicting the oldest one if the store is full.
+// Assigned IDs are the numbers of a monotonically increasing sequence.
+func (s *MemoryReplayStore) Append(id string, encode func(id string) ([]byte, error)) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := encodeReplayEntry(&s.seq, id, encode)
+	if err != nil {
+		return nil, err
+	}
+	s.ring.push(e)
+	return e.event, nil
+}
+
+// Since returns the events stored after lastEventID.
+func (s *MemoryReplayStore) Since(lastEventID string) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ring.since(lastEventID), nil
+}
+
+// FileReplayStore is a [ReplayStore] that persists events to an append-only
+// file so they survive a server restart. The most recent events are also
+// kept in memory; the file is compacted once it holds twice that many.
+type FileReplayStore struct {
+	mu      sync.Mutex
+	path    string
+	f       *os.File
+	seq     uint64
+	ring    replayRing
+	written int
+	// compactAt is the number of written records that triggers compaction
+	compactAt  int
+	compactErr error
+}
+
+// OpenFileReplayStore opens or creates a [FileReplayStore] at path that keeps
+// at most size events. Events already in the file are loaded.
+func OpenFileReplayStore(path string, size int) (*FileReplayStore, error) {
+	s := &FileReplayStore{
+		path: path,
+		ring: newReplayRing(size),
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replay file: %w", err)
+	}
+
+	var offset int64
+	r := bufio.NewReader(f)
+	for {
+		e, n, err := readReplayEntry(r)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to load replay file: %w", err)
+		}
+		offset += n
+		s.ring.push(e)
+		s.written++
+		if n, err := strconv.ParseUint(e.id, 10, 64); err == nil && n > s.seq {
+			s.seq = n
+		}
+	}
+
+	s.compactAt = 2 * len(s.ring.entries)
+
+	// drop a record truncated by a crash so new records stay readable
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to truncate replay file: %w", err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to seek replay file: %w", err)
+	}
+	s.f = f
+
+	return s, nil
+}
+
+// Append writes the event to the file and keeps it in memory. Assigned
+// IDs are the numbers of a monotonically increasing sequence, continuing
+// from the highest numeric ID found in the file. The event is stored once
+// it is written, so a failed compaction does not fail Append; it is
+// reported by [FileReplayStore.CompactErr] and retried after another
+// size events.
+func (s *FileReplayStore) Append(id string, encode func(id string) ([]byte, error)) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	offset, err := s.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seek replay file: %w", err)
+	}
+	seq := s.seq
+	e, err := encodeReplayEntry(&seq, id, encode)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeReplayEntry(s.f, e); err != nil {
+		// drop a partially written record so later records stay readable
+		if _, seekErr := s.f.Seek(offset, io.SeekStart); seekErr != nil {
+			err = errors.Join(err, seekErr)
+		} else if truncErr := s.f.Truncate(offset); truncErr != nil {
+			err = errors.Join(err, truncErr)
+		}
+		return nil, fmt.Errorf("failed to append to replay file: %w", err)
+	}
+	s.seq = seq
+	s.ring.push(e)
+	s.written++
+
+	if s.written >= s.compactAt {
+		s.compactErr = s.compactLocked()
+		if s.compactErr != nil {
+			s.compactAt = s.written + len(s.ring.entries)
+		} else {
+			s.compactAt = 2 * len(s.ring.entries)
+		}
+	}
+	return e.event, nil
+}
+
+// CompactErr returns the error of the last compaction of the file,
+// or nil if it succeeded.
+func (s *FileReplayStore) CompactErr() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.compactErr != nil {
+		return fmt.Errorf("failed to compact replay file: %w", s.compactErr)
+	}
+	return nil
+}
+
+// Since returns the events stored after lastEventID.
+func (s *FileReplayStore) Since(lastEventID string) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ring.since(lastEventID), nil
+}
+
+// Close closes the underlying file.
+func (s *FileReplayStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+// compactLocked rewrites the file with only the events held in memory.
+func (s *FileReplayStore) compactLocked() error {
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(tmp)
+	entries := s.ring.all()
+	for _, e := range entries {
+		if err := writeReplayEntry(w, e); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := errors.Join(w.Flush(), tmp.Sync()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	s.f.Close()
+	s.f = tmp
+	s.written = len(entries)
+	return nil
+}
+
+// encodeReplayEntry encodes an event for a store whose last assigned ID is
+// *seq, assigning and counting the next one if id is empty.
+func encodeReplayEntry(seq *uint64, id string, encode func(id string) ([]byte, error)) (replayEntry, error) {
+	next := *seq
+	if id == "" {
+		next++
+		id = strconv.FormatUint(next, 10)
+	}
+	event, err := encode(id)
+	if err != nil {
+		return replayEntry{}, err
+	}
+	*seq = next
+	return replayEntry{id: id, event: event}, nil
+}
+
+// writeReplayEntry writes one length-prefixed record in a single write.
+func writeReplayEntry(w io.Writer, e replayEntry) error {
+	record := make([]byte, 0, 2*binary.MaxVarintLen64+len(e.id)+len(e.event))
+	record = binary.AppendUvarint(record, uint64(len(e.id)))
+	record = binary.AppendUvarint(record, uint64(len(e.event)))
+	record = append(record, e.id...)
+	record = append(record, e.event...)
+	return writeJustError(w, record)
+}
+
+// readReplayEntry reads one record written by writeReplayEntry and returns
+// its size in bytes. A truncated trailing record is treated as the end of the file.
+func readReplayEntry(r *bufio.Reader) (replayEntry, int64, error) {
+	idLen, err := binary.ReadUvarint(r)
+	if err != nil {
+		return replayEntry{}, 0, io.EOF
+	}
+	eventLen, err := binary.ReadUvarint(r)
+	if err != nil {
+		return replayEntry{}, 0, io.EOF
+	}
+	if idLen+eventLen > maxReplayEntrySize {
+		return replayEntry{}, 0, fmt.Errorf("record of %d bytes exceeds limit", idLen+eventLen)
+	}
+	b := make([]byte, idLen+eventLen)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return replayEntry{}, 0, io.EOF
+	}
+	size := len(binary.AppendUvarint(nil, idLen)) + len(binary.AppendUvarint(nil, eventLen)) + len(b)
+	return replayEntry{id: string(b[:idLen]), event: b[idLen:]}, int64(size), nil
+}
@@ -0,0 +1,171 @@
+package datastar
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSSEReplayLastEventID(t *testing.T) {
+	store := NewMemoryReplayStore(3)
+
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil), WithReplay(store))
+	for _, d := range []string{"a", "b", "c", "d"} {
+		if err := sse.Send(EventTypePatchElements, []string{d}); err != nil {
+			t.Fatalf("Expected no error when sending, got: %v", err)
+		}
+	}
+	if !strings.Contains(w.Body.String(), "id: 4\n") {
+		t.Errorf("Expected auto-assigned event id, got: %q", w.Body.String())
+	}
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Last-Event-ID", "2")
+	w = httptest.NewRecorder()
+	NewSSE(w, req, WithReplay(store))
+
+	want := "id: 3\nevent: datastar-patch-elements\ndata: c\n\n\n" +
+		"id: 4\nevent: datastar-patch-elements\ndata: d\n\n\n"
+	if w.Body.String() != want {
+		t.Errorf("Expected replayed events %q, got: %q", want, w.Body.String())
+	}
+}
+
+// failingReplayStore fails every replay.
+type failingReplayStore struct {
+	*MemoryReplayStore
+}
+
+func (failingReplayStore) Since(string) ([][]byte, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func TestSSEReplayFailure(t *testing.T) {
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Last-Event-ID", "2")
+	sse := NewSSE(httptest.NewRecorder(), req, WithReplay(failingReplayStore{NewMemoryReplayStore(2)}))
+
+	if err := sse.Send(EventTypePatchElements, []string{"a"}); !errors.Is(err, ErrReplayFailed) {
+		t.Errorf("Expected ErrReplayFailed, got: %v", err)
+	}
+}
+
+// encodeData returns an encode function for [ReplayStore.Append]
+// that ignores the ID.
+func encodeData(d string) func(string) ([]byte, error) {
+	return func(string) ([]byte, error) { return []byte(d), nil }
+}
+
+func TestSSEReplayEvictedID(t *testing.T) {
+	store := NewMemoryReplayStore(2)
+	for _, d := range []string{"a", "b", "c"} {
+		store.Append("", encodeData(d))
+	}
+
+	events, _ := store.Since("1")
+	if len(events) != 2 || string(events[0]) != "b" {
+		t.Errorf("Expected every stored event for an evicted id, got: %q", events)
+	}
+	events, _ = store.Since("3")
+	if len(events) != 0 {
+		t.Errorf("Expected no events after the latest id, got: %q", events)
+	}
+}
+
+func TestFileReplayStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replay")
+
+	store, err := OpenFileReplayStore(path, 2)
+	if err != nil {
+		t.Fatalf("Expected no error opening store, got: %v", err)
+	}
+	for _, d := range []string{"a", "b", "c", "d", "e"} {
+		if _, err := store.Append("", encodeData(d)); err != nil {
+			t.Fatalf("Expected no error appending, got: %v", err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Expected no error closing store, got: %v", err)
+	}
+
+	store, err = OpenFileReplayStore(path, 2)
+	if err != nil {
+		t.Fatalf("Expected no error reopening store, got: %v", err)
+	}
+	defer store.Close()
+
+	events, _ := store.Since("4")
+	if len(events) != 1 || string(events[0]) != "e" {
+		t.Errorf("Expected the event after id 4, got: %q", events)
+	}
+	event, _ := store.Append("", func(id string) ([]byte, error) { return []byte(id), nil })
+	if string(event) != "6" {
+		t.Errorf("Expected ids to continue after reopening, got: %q", event)
+	}
+}
+
+func TestFileReplayStoreCompactFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replay")
+	store, err := OpenFileReplayStore(path, 2)
+	if err != nil {
+		t.Fatalf("Expected no error opening store, got: %v", err)
+	}
+	defer store.Close()
+
+	// a directory in place of the temporary file makes compaction fail
+	if err := os.Mkdir(path+".tmp", 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{"a", "b", "c", "d"} {
+		if _, err := store.Append("", encodeData(d)); err != nil {
+			t.Fatalf("Expected a stored event not to fail Append, got: %v", err)
+		}
+	}
+	if store.CompactErr() == nil {
+		t.Error("Expected the compaction failure to be reported")
+	}
+	if events, _ := store.Since("3"); len(events) != 1 || string(events[0]) != "d" {
+		t.Errorf("Expected the event stored despite the compaction failure, got: %q", events)
+	}
+
+	if err := os.Remove(path + ".tmp"); err != nil {
+		t.Fatal(err)
+	}
+	store.Append("", encodeData("e"))
+	if err := store.CompactErr(); err == nil {
+		t.Error("Expected compaction to wait before it is retried")
+	}
+	store.Append("", encodeData("f"))
+	if err := store.CompactErr(); err != nil {
+		t.Errorf("Expected the retried compaction to succeed, got: %v", err)
+	}
+}
+
+func TestReplayStoreSharedOrder(t *testing.T) {
+	store := NewMemoryReplayStore(1000)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				store.Append("", func(id string) ([]byte, error) { return []byte(id), nil })
+			}
+		}()
+	}
+	wg.Wait()
+
+	events, _ := store.Since("")
+	for i, event := range events {
+		if want := strconv.Itoa(i + 1); string(event) != want {
+			t.Fatalf("Expected events stored in ID order, got %q at %d", event, i)
+		}
+	}
+}
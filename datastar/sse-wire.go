@@ -0,0 +1,72 @@
+package datastar
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// wireBreakChars are the bytes that end an SSE line, or that clients may
+// treat as such. They never reach the wire unescaped.
+const wireBreakChars = "\r\n\x00"
+
+// nulReplacement replaces NUL bytes in data line content, as HTML parsers do.
+const nulReplacement = "\uFFFD"
+
+// ErrInvalidWireValue is matched by every [WireValueError].
+var ErrInvalidWireValue = errors.New("value would break event framing")
+
+// WireValueError reports an event field holding a CR, LF or NUL byte,
+// which would end the field early and let the rest of the value inject
+// fields or whole events. Nothing is sent when it is returned.
+//
+// Single-line fields, such as event types, event IDs, selectors and the other
+// values set with options, are rejected. Multi-line content, such as elements
+// and signals, is split into data lines instead.
+type WireValueError struct {
+	// Field names the rejected field, such as "event id" or "selector".
+	Field string
+	// Value is the rejected value.
+	Value string
+}
+
+// Error implements [error].
+func (e *WireValueError) Error() string {
+	return fmt.Sprintf("invalid %s %q: contains CR, LF or NUL", e.Field, e.Value)
+}
+
+// Unwrap returns [ErrInvalidWireValue].
+func (e *WireValueError) Unwrap() error {
+	return ErrInvalidWireValue
+}
+
+// checkWireValue returns a [WireValueError] if value cannot be written
+// into a single SSE field.
+func checkWireValue(field, value string) error {
+	if strings.ContainsAny(value, wireBreakChars) {
+		return &WireValueError{Field: field, Value: value}
+	}
+	return nil
+}
+
+// checkWireEvent validates every single-line field of an event.
+func checkWireEvent(evt *serverSentEventData) error {
+	if err := checkWireValue("event type", string(evt.Type)); err != nil {
+		return err
+	}
+	if err := checkWireValue("event id", evt.EventID); err != nil {
+		return err
+	}
+	for _, d := range evt.Data {
+		if !strings.ContainsAny(d, wireBreakChars) {
+			continue
+		}
+		// data lines set by options start with their name, such as "selector "
+		field, value, ok := strings.Cut(d, " ")
+		if !ok || strings.ContainsAny(field, wireBreakChars) {
+			field, value = "data line", d
+		}
+		return &WireValueError{Field: field, Value: value}
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package datastar
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// parseFraming splits a response body into events the way an SSE client
+// does and fails the test if any line is not a known field.
+func parseFraming(t *testing.T, body string) []map[string][]string {
+	t.Helper()
+	if strings.ContainsAny(body, "\r\x00") {
+		t.Fatalf("Expected no CR or NUL on the wire, got: %q", body)
+	}
+
+	var events []map[string][]string
+	var current map[string][]string
+	for _, line := range strings.Split(body, "\n") {
+		if line == "" {
+			if current != nil {
+				events = append(events, current)
+				current = nil
+			}
+			continue
+		}
+		field, value, ok := strings.Cut(line, ": ")
+		switch {
+		case !ok:
+			t.Fatalf("Expected a field line, got: %q in %q", line, body)
+		case field != "event" && field != "id" && field != "retry" && field != "data":
+			t.Fatalf("Expected a known field, got: %q in %q", line, body)
+		}
+		if current == nil {
+			current = map[string][]string{}
+		}
+		current[field] = append(current[field], value)
+	}
+	if current != nil {
+		t.Fatalf("Expected the last event to be terminated, got: %q", body)
+	}
+	return events
+}
+
+func TestWireValueRejected(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+
+	tests := []struct {
+		name  string
+		field string
+		send  func() error
+	}{
+		{"selector", "selector", func() error {
+			return sse.PatchElements("<div></div>", WithSelectorf("#%s", "a\nevent: evil"))
+		}},
+		{"event id", "event id", func() error {
+			return sse.PatchElements("<div></div>", WithPatchElementsEventID("1\r\ndata: x"))
+		}},
+		{"signals event id", "event id", func() error {
+			return sse.PatchSignals([]byte(`{}`), WithPatchSignalsEventID("1\x00"))
+		}},
+		{"event type", "event type", func() error {
+			return sse.Send(EventType("x\n"), nil)
+		}},
+		{"data line", "data line", func() error {
+			return sse.Send(EventTypePatchElements, []string{"a\nb"})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.send()
+			var wireErr *WireValueError
+			if !errors.As(err, &wireErr) || !errors.Is(err, ErrInvalidWireValue) {
+				t.Fatalf("Expected a WireValueError, got: %v", err)
+			}
+			if wireErr.Field != tt.field {
+				t.Errorf("Expected field %q, got: %q", tt.field, wireErr.Field)
+			}
+		})
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected nothing sent, got: %q", w.Body.String())
+	}
+
+	if _, err := NewPatchElementsEvent("<div></div>", WithSelector("a\rb")); !errors.Is(err, ErrInvalidWireValue) {
+		t.Errorf("Expected ErrInvalidWireValue from NewPatchElementsEvent, got: %v", err)
+	}
+	ew := sse.ElementsWriter(WithSelector("a\nb"))
+	if _, err := ew.Write([]byte("<div></div>")); !errors.Is(err, ErrInvalidWireValue) {
+		t.Errorf("Expected ErrInvalidWireValue from ElementsWriter, got: %v", err)
+	}
+	if err := ew.Close(); !errors.Is(err, ErrInvalidWireValue) {
+		t.Errorf("Expected ErrInvalidWireValue closing ElementsWriter, got: %v", err)
+	}
+}
+
+func TestElementsLineBreakNormalization(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+
+	if err := sse.PatchElements("<a>\r\n<b>\r<c>\x00</c>\n</a>"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	ew := sse.ElementsWriter()
+	ew.Write([]byte("<a>\r"))
+	ew.Write([]byte("\n<b>\r"))
+	ew.Write([]byte("<c>"))
+	if err := ew.Close(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	events := parseFraming(t, w.Body.String())
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got: %d", len(events))
+	}
+	want := []string{"elements <a>", "elements <b>", "elements <c>�</c>", "elements </a>"}
+	if got := events[0]["data"]; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected data lines %q, got: %q", want, got)
+	}
+	want = []string{"elements <a>", "elements <b>", "elements <c>"}
+	if got := events[1]["data"]; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected data lines %q, got: %q", want, got)
+	}
+}
+
+// normalizeLines applies the line break normalization of data lines.
+func normalizeLines(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.ReplaceAll(s, "\x00", "�")
+}
+
+func FuzzPatchElementsFraming(f *testing.F) {
+	f.Add("#a", "1", "<div>\n</div>")
+	f.Add("#a\nevent: x", "1\r", "<div>\r\n</div>\r")
+	f.Add("", "", "\x00\n\n\r\r\n")
+	f.Add("div > p", "id: 2", "data: evil\n\nevent: evil\n")
+
+	f.Fuzz(func(t *testing.T, selector, id, elements string) {
+		w := httptest.NewRecorder()
+		sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+
+		err := sse.PatchElements(elements, WithSelector(selector), WithPatchElementsEventID(id), WithModeInner())
+		if err != nil {
+			if !errors.Is(err, ErrInvalidWireValue) {
+				t.Fatalf("Expected only wire value errors, got: %v", err)
+			}
+			if w.Body.Len() != 0 {
+				t.Fatalf("Expected nothing sent after an error, got: %q", w.Body.String())
+			}
+			return
+		}
+
+		events := parseFraming(t, w.Body.String())
+		if len(events) != 1 {
+			t.Fatalf("Expected exactly one event, got: %d in %q", len(events), w.Body.String())
+		}
+		evt := events[0]
+		if got := evt["event"]; len(got) != 1 || got[0] != string(EventTypePatchElements) {
+			t.Fatalf("Expected the event type to survive, got: %q", got)
+		}
+		if id != "" && (len(evt["id"]) != 1 || evt["id"][0] != id) {
+			t.Fatalf("Expected id %q, got: %q", id, evt["id"])
+		}
+
+		var content []string
+		for _, d := range evt["data"] {
+			if v, ok := strings.CutPrefix(d, ElementsDatalineLiteral); ok {
+				content = append(content, v)
+			}
+		}
+		if elements != "" && strings.Join(content, "\n") != normalizeLines(elements) {
+			t.Fatalf("Expected elements %q, got: %q", normalizeLines(elements), content)
+		}
+	})
+}
+
+func FuzzPatchSignalsFraming(f *testing.F) {
+	f.Add("1", `{"a":1}`)
+	f.Add("1\n", "{\r\n\"a\":\r1}\n\nevent: x")
+
+	f.Fuzz(func(t *testing.T, id, signals string) {
+		w := httptest.NewRecorder()
+		sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+
+		if err := sse.PatchSignals([]byte(signals), WithPatchSignalsEventID(id)); err != nil {
+			if !errors.Is(err, ErrInvalidWireValue) {
+				t.Fatalf("Expected only wire value errors, got: %v", err)
+			}
+			return
+		}
+		events := parseFraming(t, w.Body.String())
+		if len(events) != 1 {
+			t.Fatalf("Expected exactly one event, got: %d in %q", len(events), w.Body.String())
+		}
+	})
+}
+
+func FuzzSendFraming(f *testing.F) {
+	f.Add("custom", "1", "line")
+	f.Add("a\nb", "1\r2", "x\x00y")
+
+	f.Fuzz(func(t *testing.T, eventType, id, line string) {
+		w := httptest.NewRecorder()
+		sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+
+		if err := sse.Send(EventType(eventType), []string{line}, WithSSEEventId(id)); err != nil {
+			if !errors.Is(err, ErrInvalidWireValue) {
+				t.Fatalf("Expected only wire value errors, got: %v", err)
+			}
+			return
+		}
+		events := parseFraming(t, w.Body.String())
+		if len(events) != 1 {
+			t.Fatalf("Expected exactly one event, got: %d in %q", len(events), w.Body.String())
+		}
+	})
+}
+
+// lowerASCII lowers the case of ASCII letters only, as the HTML tokenizer does.
+func lowerASCII(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+func FuzzExecuteScriptContents(f *testing.F) {
+	f.Add("console.log('</script>')")
+	f.Add("// <!-- " + strings.Repeat("\u212A", 10))
+	f.Add("'" + strings.Repeat("\u023A", 9) + "</script><img src=x onerror=alert(1)>'")
+	f.Add("</SCRIPT\n<!--<!---->")
+
+	f.Fuzz(func(t *testing.T, script string) {
+		w := httptest.NewRecorder()
+		sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil))
+
+		if err := sse.ExecuteScript(script); err != nil {
+			if !errors.Is(err, ErrInvalidWireValue) {
+				t.Fatalf("Expected only wire value errors, got: %v", err)
+			}
+			return
+		}
+		events := parseFraming(t, w.Body.String())
+		if len(events) != 1 {
+			t.Fatalf("Expected exactly one event, got: %d in %q", len(events), w.Body.String())
+		}
+
+		var content []string
+		for _, d := range events[0]["data"] {
+			if v, ok := strings.CutPrefix(d, ElementsDatalineLiteral); ok {
+				content = append(content, v)
+			}
+		}
+		element := lowerASCII(strings.Join(content, "\n"))
+		_, contents, ok := strings.Cut(element, ">")
+		if !ok || !strings.HasSuffix(contents, "</script>") {
+			t.Fatalf("Expected a script element, got: %q", element)
+		}
+		contents = strings.TrimSuffix(contents, "</script>")
+		if strings.Contains(contents, "</script") || strings.Contains(contents, "<!--") {
+			t.Fatalf("Expected the contents escaped, got: %q", contents)
+		}
+	})
+}
@@ -1,13 +1,16 @@
 package datastar
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/valyala/bytebufferpool"
@@ -23,6 +26,18 @@ type ServerSentEventGenerator struct {
 	shouldLogPanics bool
 	encoding        string
 	acceptEncoding  string
+	heartbeat       time.Duration
+	lastWrite       atomic.Int64
+	replay          ReplayStore
+	replayErr       error
+	queue           *sendQueue
+	writeTimeout    time.Duration
+	jsonCodec       JSONCodec
+	closed          bool
+	// stop is closed by Close to stop the background goroutines,
+	// which background tracks
+	stop       chan struct{}
+	background sync.WaitGroup
 }
 
 // SSEOption configures the initialization of an
@@ -38,9 +53,41 @@ func WithContext(ctx context.Context) SSEOption {
 	}
 }
 
+// WithHeartbeat makes the generator emit an SSE comment line (`: ping`)
+// whenever the stream has been idle for the given interval. This keeps
+// load balancers and proxies from closing long-lived connections.
+// Sending any event resets the timer. A non-positive interval disables it.
+//
+// The heartbeat writes from its own goroutine, which the response writer
+// only allows until the handler returns. Handlers must therefore
+// `defer sse.Close()`: Close stops the heartbeat and waits for it to exit.
+func WithHeartbeat(interval time.Duration) SSEOption {
+	return func(sse *ServerSentEventGenerator) {
+		sse.heartbeat = interval
+	}
+}
+
+// WithWriteTimeout sets a deadline for every write to the client using
+// [http.ResponseController.SetWriteDeadline], so a stuck connection fails
+// the write instead of hanging. It has no effect on response writers
+// that do not support deadlines.
+func WithWriteTimeout(timeout time.Duration) SSEOption {
+	return func(sse *ServerSentEventGenerator) {
+		sse.writeTimeout = timeout
+	}
+}
+
 // NewSSE upgrades an [http.ResponseWriter] to an HTTP Server-Sent Event stream.
 // The connection is kept alive until the context is canceled or the response is closed by returning from the handler.
 // Run an event loop for persistent streaming.
+//
+// The stream must not outlive the handler. Close it before returning:
+//
+//	sse := datastar.NewSSE(w, r, datastar.WithHeartbeat(15*time.Second))
+//	defer sse.Close()
+//
+// Streams with [WithHeartbeat] or [WithSendQueue] write from goroutines
+// of their own and require it; for other streams it is harmless.
 func NewSSE(w http.ResponseWriter, r *http.Request, opts ...SSEOption) *ServerSentEventGenerator {
 	rc := http.NewResponseController(w)
 
@@ -57,6 +104,7 @@ func NewSSE(w http.ResponseWriter, r *http.Request, opts ...SSEOption) *ServerSe
 		rc:              rc,
 		shouldLogPanics: true,
 		acceptEncoding:  r.Header.Get("Accept-Encoding"),
+		stop:            make(chan struct{}),
 	}
 
 	// apply options
@@ -77,6 +125,32 @@ func NewSSE(w http.ResponseWriter, r *http.Request, opts ...SSEOption) *ServerSe
 		// Programs are expected to handle errors.
 		panic(fmt.Sprintf("response writer failed to flush: %v", err))
 	}
+	sseHandler.lastWrite.Store(time.Now().UnixNano())
+
+	// replay events missed during a reconnect
+	if lastEventID := r.Header.Get("Last-Event-ID"); sseHandler.replay != nil && lastEventID != "" {
+		sseHandler.mu.Lock()
+		// the client would silently miss events, so every send reports the failure
+		if err := sseHandler.replayLocked(lastEventID); err != nil {
+			sseHandler.replayErr = fmt.Errorf("%w: %w", ErrReplayFailed, err)
+		}
+		sseHandler.mu.Unlock()
+	}
+
+	if sseHandler.queue != nil {
+		// the send queue closes the stream when the client cannot keep up
+		ctx, cancel := context.WithCancelCause(sseHandler.ctx)
+		sseHandler.ctx = ctx
+		sseHandler.queue.start(sseHandler, cancel)
+	}
+
+	if sseHandler.heartbeat > 0 {
+		sseHandler.background.Add(1)
+		go func() {
+			defer sseHandler.background.Done()
+			sseHandler.runHeartbeat()
+		}()
+	}
 
 	return sseHandler
 }
@@ -130,6 +204,10 @@ var (
 	idLinePrefix    = []byte("id: ")
 	retryLinePrefix = []byte("retry: ")
 	dataLinePrefix  = []byte("data: ")
+
+	heartbeatComment = []byte(": ping" + DoubleNewLine)
+
+	errStreamClosed = errors.New("stream closed")
 )
 
 func writeJustError(w io.Writer, b []byte) (err error) {
@@ -138,16 +216,8 @@ func writeJustError(w io.Writer, b []byte) (err error) {
 }
 
 // Send emits a server-sent event to the client. Method is safe for
-// concurrent use.
+// concurrent use. Values holding CR, LF or NUL fail with a [WireValueError].
 func (sse *ServerSentEventGenerator) Send(eventType EventType, dataLines []string, opts ...SSEEventOption) error {
-	// Check if context is cancelled before attempting to send
-	if err := sse.ctx.Err(); err != nil {
-		return fmt.Errorf("context cancelled: %w", err)
-	}
-
-	sse.mu.Lock()
-	defer sse.mu.Unlock()
-
 	// create the event
 	evt := serverSentEventData{
 		Type:          eventType,
@@ -160,9 +230,69 @@ func (sse *ServerSentEventGenerator) Send(eventType EventType, dataLines []strin
 		opt(&evt)
 	}
 
+	return sse.sendWith(&evt, nil)
+}
+
+// sendWith encodes the event, with body writing any data lines that follow
+// evt.Data, and sends it. A nil body writes none.
+func (sse *ServerSentEventGenerator) sendWith(evt *serverSentEventData, body func(*bytebufferpool.ByteBuffer) error) error {
+	// Check if context is cancelled before attempting to send
+	if err := sse.ctx.Err(); err != nil {
+		return fmt.Errorf("context cancelled: %w", err)
+	}
+
 	buf := bytebufferpool.Get()
 	defer bytebufferpool.Put(buf)
 
+	if err := encodeEvent(buf, evt, body); err != nil {
+		return err
+	}
+
+	return sse.sendEncoded(buf.B, evt)
+}
+
+// sendEncoded sends an encoded event. The caller keeps ownership of b.
+func (sse *ServerSentEventGenerator) sendEncoded(b []byte, evt *serverSentEventData) error {
+	// Check if context is cancelled before attempting to send
+	if err := sse.ctx.Err(); err != nil {
+		return fmt.Errorf("context cancelled: %w", err)
+	}
+
+	sse.mu.Lock()
+	defer sse.mu.Unlock()
+
+	if sse.closed {
+		return errStreamClosed
+	}
+	if sse.replayErr != nil {
+		return sse.replayErr
+	}
+
+	// log.Print(NewLine + string(b))
+	return sse.emitLocked(b, evt.EventID, coalesceKey(evt), false)
+}
+
+// encodeEvent writes the wire format of one event to buf. The data lines
+// written by body, if not nil, follow evt.Data.
+func encodeEvent(buf *bytebufferpool.ByteBuffer, evt *serverSentEventData, body func(*bytebufferpool.ByteBuffer) error) error {
+	if err := encodeEventHeader(buf, evt); err != nil {
+		return err
+	}
+	if body != nil {
+		if err := body(buf); err != nil {
+			return fmt.Errorf("failed to write data: %w", err)
+		}
+	}
+	return encodeEventEnd(buf)
+}
+
+// encodeEventHeader writes every field of the event up to and including evt.Data.
+// It fails with a [WireValueError] if a field would break the event framing.
+func encodeEventHeader(buf *bytebufferpool.ByteBuffer, evt *serverSentEventData) error {
+	if err := checkWireEvent(evt); err != nil {
+		return err
+	}
+
 	// write event type
 	if err := errors.Join(
 		writeJustError(buf, eventLinePrefix),
@@ -207,27 +337,262 @@ func (sse *ServerSentEventGenerator) Send(eventType EventType, dataLines []strin
 		}
 	}
 
+	return nil
+}
+
+// encodeEventEnd terminates the event.
+func encodeEventEnd(buf *bytebufferpool.ByteBuffer) error {
 	// write double newlines to separate events
 	if err := writeJustError(buf, doubleNewLineBuf); err != nil {
 		return fmt.Errorf("failed to write newline: %w", err)
 	}
 
-	// copy the buffer to the response writer
-	if _, err := buf.WriteTo(sse.w); err != nil {
-		return fmt.Errorf("failed to write to response writer: %w", err)
+	return nil
+}
+
+// dataLineWriter writes content as consecutive data lines, each starting with
+// the literal, straight into the event buffer. Every line break in the content,
+// whether \n, \r\n or \r, starts a new data line, so no intermediate line
+// slices are allocated. NUL bytes are replaced with U+FFFD.
+type dataLineWriter struct {
+	buf     *bytebufferpool.ByteBuffer
+	literal string
+	open    bool
+	// skipLF is set when the last write ended with \r, so a \n starting
+	// the next write completes the same line break.
+	skipLF bool
+}
+
+func (w *dataLineWriter) openLine() {
+	w.buf.B = append(w.buf.B, dataLinePrefix...)
+	w.buf.B = append(w.buf.B, w.literal...)
+	w.open = true
+}
+
+// Write implements [io.Writer].
+func (w *dataLineWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if w.skipLF && len(p) > 0 && p[0] == '\n' {
+		p = p[1:]
+	}
+	w.skipLF = false
+	for len(p) > 0 {
+		if !w.open {
+			w.openLine()
+		}
+		i := bytes.IndexAny(p, wireBreakChars)
+		if i < 0 {
+			w.buf.B = append(w.buf.B, p...)
+			break
+		}
+		w.buf.B = append(w.buf.B, p[:i]...)
+		p = lineBreak(w, p[i], p[i+1:])
+	}
+	return n, nil
+}
+
+// WriteString implements [io.StringWriter].
+func (w *dataLineWriter) WriteString(s string) (int, error) {
+	n := len(s)
+	if w.skipLF && len(s) > 0 && s[0] == '\n' {
+		s = s[1:]
+	}
+	w.skipLF = false
+	for len(s) > 0 {
+		if !w.open {
+			w.openLine()
+		}
+		i := strings.IndexAny(s, wireBreakChars)
+		if i < 0 {
+			w.buf.B = append(w.buf.B, s...)
+			break
+		}
+		w.buf.B = append(w.buf.B, s[:i]...)
+		s = lineBreak(w, s[i], s[i+1:])
+	}
+	return n, nil
+}
+
+// lineBreak writes the replacement of c, one of [wireBreakChars], and returns
+// the content following it.
+func lineBreak[T string | []byte](w *dataLineWriter, c byte, rest T) T {
+	if c == 0 {
+		w.buf.B = append(w.buf.B, nulReplacement...)
+		return rest
+	}
+	if c == '\r' {
+		if len(rest) == 0 {
+			w.skipLF = true
+		} else if rest[0] == '\n' {
+			rest = rest[1:]
+		}
+	}
+	w.buf.B = append(w.buf.B, '\n')
+	w.openLine()
+	return rest
+}
+
+// close terminates the current data line, if any.
+func (w *dataLineWriter) close() {
+	if w.open {
+		w.buf.B = append(w.buf.B, newLineBuf...)
+		w.open = false
+	}
+}
+
+// emitLocked stores an encoded event for replay, then writes it or hands it
+// to the send queue. If retainable is false, b is reused by the caller and
+// is copied before being kept. The caller must hold the generator mutex,
+// which is released while waiting for room in the send queue.
+func (sse *ServerSentEventGenerator) emitLocked(b []byte, id, key string, retainable bool) error {
+	at := -1
+	if sse.queue != nil {
+		// wait before storing the event, so it is replayed in the order it is queued
+		var err error
+		if at, err = sse.queue.reserveLocked(key); err != nil {
+			return err
+		}
+		if sse.closed {
+			return errStreamClosed
+		}
+	}
+
+	if sse.replay != nil {
+		stored, err := sse.replay.Append(id, func(assigned string) ([]byte, error) {
+			if assigned == id {
+				if retainable {
+					return b, nil
+				}
+				return bytes.Clone(b), nil
+			}
+			// the store assigned an event ID so the event can be replayed
+			if err := checkWireValue("event id", assigned); err != nil {
+				return nil, fmt.Errorf("failed to assign event id: %w", err)
+			}
+			withID := make([]byte, 0, len(idLinePrefix)+len(assigned)+len(newLineBuf)+len(b))
+			withID = append(withID, idLinePrefix...)
+			withID = append(withID, assigned...)
+			withID = append(withID, newLineBuf...)
+			return append(withID, b...), nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to store event for replay: %w", err)
+		}
+		b, retainable = stored, true
+	}
+
+	if sse.queue != nil {
+		if !retainable {
+			b = bytes.Clone(b)
+		}
+		sse.queue.putLocked(queuedEvent{data: b, key: key}, at)
+		return nil
+	}
+	return sse.writeLocked(b)
+}
+
+// Close stops the stream from sending further events and waits for its
+// background goroutines to exit, so nothing writes to the [http.ResponseWriter]
+// once it returns. With [WithSendQueue], it first waits until every queued
+// event has been written and returns the error that stopped the writer, if any.
+// It does not close the underlying connection, which ends when the handler
+// returns. Calling Close more than once is safe.
+func (sse *ServerSentEventGenerator) Close() error {
+	sse.mu.Lock()
+	if !sse.closed {
+		sse.closed = true
+		close(sse.stop)
+	}
+	sse.mu.Unlock()
+
+	var err error
+	if sse.queue != nil {
+		err = sse.queue.close()
+	}
+	sse.background.Wait()
+	return err
+}
+
+// streamWriteError marks a failure writing to the connection,
+// after which the stream cannot deliver events.
+type streamWriteError struct {
+	err error
+}
+
+func (e *streamWriteError) Error() string { return e.err.Error() }
+func (e *streamWriteError) Unwrap() error { return e.err }
+
+// isStreamGone reports whether err, returned by a send on the stream,
+// means the stream can no longer deliver events.
+func (sse *ServerSentEventGenerator) isStreamGone(err error) bool {
+	var writeErr *streamWriteError
+	return sse.IsClosed() || errors.Is(err, errStreamClosed) || errors.Is(err, ErrReplayFailed) ||
+		errors.Is(err, ErrSendQueueFull) || errors.As(err, &writeErr)
+}
+
+// writeLocked copies b to the response writer and flushes it.
+// The caller must hold the generator mutex, or be the send queue writer.
+func (sse *ServerSentEventGenerator) writeLocked(b []byte) error {
+	if sse.writeTimeout > 0 {
+		if err := sse.rc.SetWriteDeadline(time.Now().Add(sse.writeTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return fmt.Errorf("failed to set write deadline: %w", err)
+		}
+		defer sse.rc.SetWriteDeadline(time.Time{})
+	}
+
+	if _, err := sse.w.Write(b); err != nil {
+		return &streamWriteError{fmt.Errorf("failed to write to response writer: %w", err)}
 	}
 
 	// flush the write if its a compressing writer
 	if f, ok := sse.w.(flusher); ok {
 		if err := f.Flush(); err != nil {
-			return fmt.Errorf("failed to flush compressing writer: %w", err)
+			return &streamWriteError{fmt.Errorf("failed to flush compressing writer: %w", err)}
 		}
 	}
 
 	if err := sse.rc.Flush(); err != nil {
-		return fmt.Errorf("failed to flush data: %w", err)
+		return &streamWriteError{fmt.Errorf("failed to flush data: %w", err)}
 	}
 
-	// log.Print(NewLine + buf.String())
+	sse.lastWrite.Store(time.Now().UnixNano())
 	return nil
 }
+
+// runHeartbeat writes a comment line every time the stream has been idle
+// for the heartbeat interval. It returns when the stream is closed, the
+// context is cancelled or a write fails.
+func (sse *ServerSentEventGenerator) runHeartbeat() {
+	timer := time.NewTimer(sse.heartbeat)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-sse.stop:
+			return
+		case <-sse.ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		sse.mu.Lock()
+		if sse.ctx.Err() != nil || sse.closed {
+			sse.mu.Unlock()
+			return
+		}
+		next := sse.heartbeat
+		if idle := time.Since(time.Unix(0, sse.lastWrite.Load())); idle < sse.heartbeat {
+			// an event went out in the meantime, wait out the remainder
+			next -= idle
+		} else if sse.queue != nil {
+			// a full queue means the client is already busy
+			sse.queue.tryPushLocked(queuedEvent{data: heartbeatComment})
+		} else if err := sse.writeLocked(heartbeatComment); err != nil {
+			sse.mu.Unlock()
+			return
+		}
+		sse.mu.Unlock()
+
+		timer.Reset(next)
+	}
+}
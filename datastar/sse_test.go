@@ -3,6 +3,8 @@ package datastar
 import (
 	"context"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -67,4 +69,113 @@ func TestSSESendWithActiveConnection(t *testing.T) {
 	if contentType != "text/event-stream" {
 		t.Errorf("Expected Content-Type to be 'text/event-stream', got: %s", contentType)
 	}
-}
\ No newline at end of file
+}
+
+// waitForBody polls the body written to w until ok accepts it, failing the
+// test if that does not happen within a generous deadline.
+func waitForBody(t *testing.T, sse *ServerSentEventGenerator, w *httptest.ResponseRecorder, ok func(body string) bool) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sse.mu.Lock()
+		body := w.Body.String()
+		sse.mu.Unlock()
+		if ok(body) {
+			return body
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the body, got: %q", body)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSSEHeartbeat(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := httptest.NewRequest("GET", "/test", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	sse := NewSSE(w, req, WithHeartbeat(10*time.Millisecond))
+	defer sse.Close()
+	waitForBody(t, sse, w, func(body string) bool {
+		return strings.Contains(body, ": ping\n\n")
+	})
+}
+
+func TestSSEHeartbeatResetBySend(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := httptest.NewRequest("GET", "/test", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	const heartbeat = 50 * time.Millisecond
+	sse := NewSSE(w, req, WithHeartbeat(heartbeat))
+	defer sse.Close()
+	sent := time.Now()
+	if err := sse.Send(EventTypePatchElements, []string{"test"}); err != nil {
+		t.Fatalf("Expected no error when sending, got: %v", err)
+	}
+
+	// a ping may only follow the event once the stream was idle for the interval
+	waitForBody(t, sse, w, func(body string) bool {
+		_, after, _ := strings.Cut(body, "data: test\n")
+		return strings.Contains(after, ": ping\n\n")
+	})
+	if idle := time.Since(sent); idle < heartbeat {
+		t.Errorf("Expected the heartbeat to wait %v after the last event, got a ping after %v", heartbeat, idle)
+	}
+}
+
+// closeCheckRecorder counts writes made after the stream was closed.
+type closeCheckRecorder struct {
+	*httptest.ResponseRecorder
+	closed    atomic.Bool
+	lateWrite atomic.Int32
+}
+
+func (c *closeCheckRecorder) Write(b []byte) (int, error) {
+	if c.closed.Load() {
+		c.lateWrite.Add(1)
+	}
+	return c.ResponseRecorder.Write(b)
+}
+
+func TestSSEHeartbeatStopsOnClose(t *testing.T) {
+	w := &closeCheckRecorder{ResponseRecorder: httptest.NewRecorder()}
+	req := httptest.NewRequest("GET", "/test", nil)
+
+	sse := NewSSE(w, req, WithHeartbeat(time.Millisecond))
+	waitForBody(t, sse, w.ResponseRecorder, func(body string) bool {
+		return strings.Contains(body, ": ping\n\n")
+	})
+	if err := sse.Close(); err != nil {
+		t.Fatalf("Expected no error closing, got: %v", err)
+	}
+	w.closed.Store(true)
+
+	// Close has waited for the heartbeat goroutine to exit
+	time.Sleep(5 * time.Millisecond)
+	if n := w.lateWrite.Load(); n != 0 {
+		t.Errorf("Expected no writes after Close, got: %d", n)
+	}
+}
+
+func TestSSECloseStopsHeartbeat(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse := NewSSE(w, httptest.NewRequest("GET", "/test", nil), WithHeartbeat(time.Hour))
+
+	closed := make(chan error)
+	go func() { closed <- sse.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("Expected no error closing, got: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Close to stop the heartbeat before its timer fires")
+	}
+	if err := sse.Close(); err != nil {
+		t.Errorf("Expected closing twice to succeed, got: %v", err)
+	}
+}